@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// isBatch reports whether a payload is a JSON-RPC batch (a JSON array)
+func isBatch(data []byte) bool {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+// handleBatch processes a JSON-RPC batch. Requests run concurrently, each
+// holding a slot in sem while it runs, so a batch shares its connection's
+// concurrency limit with every other request; notifications and initialize are handled inline as they
+// are reached, because later entries may depend on them. The result is the
+// array of replies for requests, in batch order, a single error response when
+// the batch itself is invalid, or nil when nothing needs to be sent back.
+func (s *WebSearchServer) handleBatch(ctx context.Context, data []byte, sem chan struct{}) interface{} {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return &MCPMessage{
+			JSONRPC: "2.0",
+			Error: &MCPError{
+				Code:    -32700,
+				Message: "Parse error",
+				Data:    err.Error(),
+			},
+		}
+	}
+	if !supportsBatching(ctx) {
+		return &MCPMessage{
+			JSONRPC: "2.0",
+			Error: &MCPError{
+				Code:    -32600,
+				Message: "Invalid Request",
+				Data:    "batching is not supported in protocol version " + protocolVersionFor(ctx),
+			},
+		}
+	}
+	if len(entries) == 0 {
+		return &MCPMessage{
+			JSONRPC: "2.0",
+			Error: &MCPError{
+				Code:    -32600,
+				Message: "Invalid Request",
+				Data:    "empty batch",
+			},
+		}
+	}
+
+	replies := make([]*MCPMessage, len(entries))
+	var wg sync.WaitGroup
+	for i, raw := range entries {
+		msg, errResponse := decodeMessage(raw)
+		if errResponse != nil {
+			s.stats.IncrementErrors()
+			replies[i] = errResponse
+			continue
+		}
+
+		s.stats.IncrementRequests()
+		if msg.isNotification() || msg.isResponse() || msg.Method == "initialize" {
+			replies[i] = s.handleMessage(ctx, msg)
+			continue
+		}
+
+		reqCtx, finish := s.inFlightFor(ctx).track(ctx, msg.ID)
+		wg.Add(1)
+		go func(i int, msg MCPMessage) {
+			defer wg.Done()
+			replies[i] = s.handleQueued(reqCtx, sem, msg, finish)
+		}(i, msg)
+	}
+	wg.Wait()
+
+	out := make([]*MCPMessage, 0, len(replies))
+	for _, r := range replies {
+		if r != nil {
+			out = append(out, r)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWebSearchServer_HandleBatch(t *testing.T) {
+	server := NewWebSearchServer()
+	ctx := withSession(context.Background(), NewSession())
+
+	batch := `[
+		{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}},
+		{"jsonrpc":"2.0","method":"notifications/initialized"},
+		{"jsonrpc":"2.0","id":2,"method":"ping"},
+		{"jsonrpc":"2.0","id":3,"method":"nope"}
+	]`
+	reply, ok := server.handleBatch(ctx, []byte(batch), make(chan struct{}, 4)).([]*MCPMessage)
+	if !ok {
+		t.Fatalf("Expected a batch reply, got %T", reply)
+	}
+	if len(reply) != 3 {
+		t.Fatalf("Expected 3 replies (one per request), got %d", len(reply))
+	}
+	if reply[1].Result != "pong" {
+		t.Errorf("Expected ping reply in batch order, got %+v", reply[1])
+	}
+	if reply[2].Error == nil || reply[2].Error.Code != -32601 {
+		t.Errorf("Expected method not found for unknown method, got %+v", reply[2])
+	}
+
+	if reply := server.handleBatch(ctx, []byte(`[{"jsonrpc":"2.0","method":"notifications/initialized"}]`), make(chan struct{}, 4)); reply != nil {
+		t.Errorf("Expected no reply for a batch of notifications, got %+v", reply)
+	}
+
+	empty, ok := server.handleBatch(ctx, []byte(`[]`), make(chan struct{}, 4)).(*MCPMessage)
+	if !ok || empty.Error == nil || empty.Error.Code != -32600 {
+		t.Errorf("Expected a single -32600 for an empty batch, got %+v", empty)
+	}
+}
+
+func TestWebSearchServer_HandleBatchStopsWaitingWhenCancelled(t *testing.T) {
+	server := NewWebSearchServer()
+	ctx, cancel := context.WithCancel(withSession(context.Background(), NewSession()))
+
+	// Every slot is taken, as if other requests on the connection were running
+	sem := make(chan struct{}, 1)
+	sem <- struct{}{}
+
+	done := make(chan interface{}, 1)
+	go func() {
+		done <- server.handleBatch(ctx, []byte(`[{"jsonrpc":"2.0","id":1,"method":"ping"}]`), sem)
+	}()
+	cancel()
+
+	select {
+	case reply := <-done:
+		if reply != nil {
+			t.Errorf("Expected no reply for a batch cancelled while queued, got %+v", reply)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Batch kept waiting for a slot after its context was cancelled")
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// pageMarker recognises a kind of page by the elements or text on it, such
+// as a scraped engine's "no results" notice or its CAPTCHA interstitial.
+// Block pages are served with status 200, so without checking they look
+// like an ordinary page with no results.
+type pageMarker struct {
+	Selectors []string `json:"selectors,omitempty"`
+	// Phrases are matched case-insensitively against the page text
+	Phrases []string `json:"phrases,omitempty"`
+}
+
+var errBlockPage = errors.New("served a CAPTCHA or block page instead of results")
+
+func (m pageMarker) matches(doc *goquery.Document) bool {
+	for _, sel := range m.Selectors {
+		if doc.Find(sel).Length() > 0 {
+			return true
+		}
+	}
+	if len(m.Phrases) == 0 {
+		return false
+	}
+	text := strings.ToLower(doc.Text())
+	for _, phrase := range m.Phrases {
+		if strings.Contains(text, strings.ToLower(phrase)) {
+			return true
+		}
+	}
+	return false
+}
+
+// providerBackoff takes providers that blocked us out of rotation, doubling
+// the pause on each consecutive block up to max
+type providerBackoff struct {
+	base, max time.Duration
+
+	mu     sync.Mutex
+	blocks map[string]int
+	until  map[string]time.Time
+}
+
+func newProviderBackoff(base, max time.Duration) *providerBackoff {
+	return &providerBackoff{
+		base:   base,
+		max:    max,
+		blocks: make(map[string]int),
+		until:  make(map[string]time.Time),
+	}
+}
+
+// block records a block from provider and returns how long it is benched for
+func (b *providerBackoff) block(provider string) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.blocks[provider]++
+	pause := b.base
+	for i := 1; i < b.blocks[provider] && pause < b.max; i++ {
+		pause *= 2
+	}
+	if pause > b.max {
+		pause = b.max
+	}
+	b.until[provider] = time.Now().Add(pause)
+	return pause
+}
+
+// reset puts provider back in rotation after a successful search
+func (b *providerBackoff) reset(provider string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.blocks, provider)
+	delete(b.until, provider)
+}
+
+// benchedUntil reports when provider may be used again, if it is benched
+func (b *providerBackoff) benchedUntil(provider string) (time.Time, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	until, ok := b.until[provider]
+	if !ok || time.Now().After(until) {
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// available filters out benched providers. When every provider is benched
+// they are all returned, since trying a blocked engine beats not searching.
+func (b *providerBackoff) available(providers []SearchProvider) []SearchProvider {
+	out := make([]SearchProvider, 0, len(providers))
+	for _, p := range providers {
+		if _, benched := b.benchedUntil(p.Name()); !benched {
+			out = append(out, p)
+		}
+	}
+	if len(out) == 0 {
+		return providers
+	}
+	return out
+}
+
+// snapshot returns the benched providers and when each returns, for stats
+func (b *providerBackoff) snapshot() map[string]time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make(map[string]time.Time)
+	now := time.Now()
+	for name, until := range b.until {
+		if until.After(now) {
+			out[name] = until
+		}
+	}
+	return out
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// resultCache is an LRU cache of search responses whose entries expire after
+// ttl
+type resultCache struct {
+	ttl     time.Duration
+	maxSize int
+
+	mu      sync.Mutex
+	order   *list.List // front is most recently used
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key     string
+	res     *SearchResponse
+	expires time.Time
+}
+
+func newResultCache(ttl time.Duration, maxSize int) *resultCache {
+	return &resultCache{
+		ttl:     ttl,
+		maxSize: maxSize,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *resultCache) get(key string) (*SearchResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return entry.res.clone(), true
+}
+
+func (c *resultCache) put(key string, res *SearchResponse) {
+	if c.maxSize <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &cacheEntry{key: key, res: res.clone(), expires: time.Now().Add(c.ttl)}
+	if el, ok := c.entries[key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.maxSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *resultCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// clone copies a response so cached entries are never shared with callers,
+// which fill in per-request fields such as the duration
+func (r *SearchResponse) clone() *SearchResponse {
+	out := *r
+	out.Results = append([]SearchResult(nil), r.Results...)
+	out.Providers = append([]string(nil), r.Providers...)
+	return &out
+}
+
+// cacheKey identifies a search by its normalized query, the strategy and
+// providers that would answer it, and the options that shape the result
+func cacheKey(mode string, providers []SearchProvider, q Query) string {
+	names := make([]string, len(providers))
+	for i, p := range providers {
+		names[i] = p.Name()
+	}
+	query := strings.Join(strings.Fields(strings.ToLower(q.Text)), " ")
+	return fmt.Sprintf("%s|%s|%d|%s", mode, strings.Join(names, ","), q.MaxResults, query)
+}
+
+// flightGroup coalesces concurrent identical searches into one upstream
+// request. The shared request only stops once every caller waiting on it has
+// given up.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+type flight struct {
+	done   chan struct{}
+	res    *SearchResponse
+	err    error
+	refs   int
+	cancel context.CancelFunc
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{calls: make(map[string]*flight)}
+}
+
+// do runs search once per key at a time. shared reports whether the caller
+// joined a search started by someone else.
+func (g *flightGroup) do(ctx context.Context, key string, search func(context.Context) (*SearchResponse, error)) (res *SearchResponse, shared bool, err error) {
+	g.mu.Lock()
+	f, shared := g.calls[key]
+	if !shared {
+		// Detached from ctx so one caller leaving does not fail the others,
+		// but keeping its deadline so rate limiting can still plan around it
+		var (
+			searchCtx context.Context
+			cancel    context.CancelFunc
+		)
+		if deadline, ok := ctx.Deadline(); ok {
+			searchCtx, cancel = context.WithDeadline(context.WithoutCancel(ctx), deadline)
+		} else {
+			searchCtx, cancel = context.WithCancel(context.WithoutCancel(ctx))
+		}
+		f = &flight{done: make(chan struct{}), cancel: cancel}
+		g.calls[key] = f
+		go func() {
+			f.res, f.err = search(searchCtx)
+			g.forget(key, f)
+			cancel()
+			close(f.done)
+		}()
+	}
+	f.refs++
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		if f.err != nil {
+			return nil, shared, f.err
+		}
+		return f.res.clone(), shared, nil
+	case <-ctx.Done():
+		g.mu.Lock()
+		f.refs--
+		if f.refs == 0 {
+			// Nobody is waiting any more; later callers start afresh
+			if g.calls[key] == f {
+				delete(g.calls, key)
+			}
+			f.cancel()
+		}
+		g.mu.Unlock()
+		return nil, shared, ctx.Err()
+	}
+}
+
+// forget removes f from the group unless a newer flight has taken its key
+func (g *flightGroup) forget(key string, f *flight) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.calls[key] == f {
+		delete(g.calls, key)
+	}
+}
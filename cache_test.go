@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestResultCache_LRUAndTTL(t *testing.T) {
+	c := newResultCache(time.Hour, 2)
+	c.put("a", &SearchResponse{Query: "a"})
+	c.put("b", &SearchResponse{Query: "b"})
+	c.get("a")
+	c.put("c", &SearchResponse{Query: "c"})
+
+	if _, ok := c.get("b"); ok {
+		t.Error("Expected the least recently used entry to be evicted")
+	}
+	if _, ok := c.get("a"); !ok {
+		t.Error("Expected a recently used entry to survive")
+	}
+
+	res, _ := c.get("a")
+	res.Query = "changed"
+	if again, _ := c.get("a"); again.Query != "a" {
+		t.Error("Expected callers to get copies of cached entries")
+	}
+
+	short := newResultCache(time.Millisecond, 2)
+	short.put("a", &SearchResponse{Query: "a"})
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := short.get("a"); ok {
+		t.Error("Expected the entry to expire")
+	}
+}
+
+func TestCacheKey_NormalizesQuery(t *testing.T) {
+	providers := []SearchProvider{&stubProvider{name: "a"}}
+	k1 := cacheKey("auto", providers, Query{Text: "  Go   Channels ", MaxResults: 5})
+	k2 := cacheKey("auto", providers, Query{Text: "go channels", MaxResults: 5})
+	if k1 != k2 {
+		t.Errorf("Expected equal keys, got %q and %q", k1, k2)
+	}
+	if k1 == cacheKey("auto", providers, Query{Text: "go channels", MaxResults: 10}) {
+		t.Error("Expected max results to be part of the key")
+	}
+}
+
+// gatedProvider blocks every search until release is closed
+type gatedProvider struct {
+	stubProvider
+	release chan struct{}
+	mu      sync.Mutex
+}
+
+func (p *gatedProvider) Search(ctx context.Context, q Query) (*SearchResponse, error) {
+	<-p.release
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stubProvider.Search(ctx, q)
+}
+
+func TestWebSearchServer_CacheAndCoalescing(t *testing.T) {
+	p := &gatedProvider{
+		stubProvider: stubProvider{name: "gated", results: []SearchResult{{Title: "Hit", URL: "https://example.com", Rank: 1}}},
+		release:      make(chan struct{}),
+	}
+	server := newTestServer(p)
+	server.cache = newResultCache(time.Hour, 10)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if res, err := server.performWebSearch(context.Background(), "golang", 5); err != nil || res.Count != 1 {
+				t.Errorf("Expected a result, got %v %v", res, err)
+			}
+		}()
+	}
+	// Let all three join the same flight before the provider answers
+	waiting := func() int {
+		server.flights.mu.Lock()
+		defer server.flights.mu.Unlock()
+		for _, f := range server.flights.calls {
+			return f.refs
+		}
+		return 0
+	}
+	for waiting() != 3 {
+		time.Sleep(time.Millisecond)
+	}
+	close(p.release)
+	wg.Wait()
+
+	if _, err := server.performWebSearch(context.Background(), "GoLang", 5); err != nil {
+		t.Fatal(err)
+	}
+	if p.calls != 1 {
+		t.Errorf("Expected one upstream search, got %d", p.calls)
+	}
+	cache := server.statsSnapshot()["cache"].(map[string]interface{})
+	if cache["hits"] != int64(1) || cache["coalesced"] != int64(2) || cache["entries"] != 1 {
+		t.Errorf("Unexpected cache stats: %v", cache)
+	}
+}
+
+func TestWebSearchServer_DegradedAnswersAreNotCached(t *testing.T) {
+	results := []SearchResult{{Title: "Hit", URL: "https://example.com", Rank: 1}}
+
+	t.Run("earlier provider failed", func(t *testing.T) {
+		failing := &stubProvider{name: "first", err: newProviderError("first", ErrNetwork, errors.New("connection reset"))}
+		fallback := &stubProvider{name: "second", results: results}
+		server := newTestServer(failing, fallback)
+		server.cache = newResultCache(time.Hour, 10)
+
+		for i := 0; i < 2; i++ {
+			if _, err := server.performWebSearch(context.Background(), "golang", 5); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if fallback.calls != 2 || server.cache.len() != 0 {
+			t.Errorf("Expected the fallback answer not to be cached, got %d calls and %d entries", fallback.calls, server.cache.len())
+		}
+	})
+
+	t.Run("merge missing a provider", func(t *testing.T) {
+		t.Setenv("SEARCH_PROVIDER", "merge")
+		failing := &stubProvider{name: "first", err: newProviderError("first", ErrTimeout, context.DeadlineExceeded)}
+		answered := &stubProvider{name: "second", results: results}
+		server := newTestServer(failing, answered)
+		server.cache = newResultCache(time.Hour, 10)
+
+		if _, err := server.performWebSearch(context.Background(), "golang", 5); err != nil {
+			t.Fatal(err)
+		}
+		if server.cache.len() != 0 {
+			t.Errorf("Expected a partial merge not to be cached, got %d entries", server.cache.len())
+		}
+	})
+
+	t.Run("earlier provider out of rotation", func(t *testing.T) {
+		benched := &stubProvider{name: "first", results: results}
+		fallback := &stubProvider{name: "second", results: results}
+		server := newTestServer(benched, fallback)
+		server.cache = newResultCache(time.Hour, 10)
+		server.backoff.block("first")
+
+		if _, err := server.performWebSearch(context.Background(), "golang", 5); err != nil {
+			t.Fatal(err)
+		}
+		if benched.calls != 0 || fallback.calls != 1 || server.cache.len() != 0 {
+			t.Errorf("Expected an uncached fallback answer, got %d/%d calls and %d entries", benched.calls, fallback.calls, server.cache.len())
+		}
+	})
+
+	t.Run("later provider out of rotation", func(t *testing.T) {
+		first := &stubProvider{name: "first", results: results}
+		benched := &stubProvider{name: "second", results: results}
+		server := newTestServer(first, benched)
+		server.cache = newResultCache(time.Hour, 10)
+		server.backoff.block("second")
+
+		if _, err := server.performWebSearch(context.Background(), "golang", 5); err != nil {
+			t.Fatal(err)
+		}
+		if server.cache.len() != 1 {
+			t.Errorf("Expected the first provider's answer to be cached, got %d entries", server.cache.len())
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// trackingParams are query parameters that only identify a campaign or click
+// and never change the page served
+var trackingParams = map[string]bool{
+	"gclid":   true,
+	"dclid":   true,
+	"fbclid":  true,
+	"msclkid": true,
+	"yclid":   true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"igshid":  true,
+	"ref_src": true,
+	"_hsenc":  true,
+	"_hsmi":   true,
+}
+
+func isTrackingParam(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasPrefix(name, "utm_") || trackingParams[name]
+}
+
+// canonicalizeURL returns two forms of raw:
+//   - clean: the URL to show, with tracking parameters and fragment removed
+//     but scheme and host otherwise as scraped
+//   - canonical: an https URL used to spot duplicates, which additionally
+//     drops "www." and mobile Wikipedia hosts, default ports, trailing
+//     slashes and query order
+//
+// The query is only rewritten when it holds tracking parameters, and then
+// pair by pair, so parameter order, valueless keys and separators such as
+// ";" survive. Unparseable input is returned unchanged in both forms.
+func canonicalizeURL(raw string) (clean string, canonical string) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return raw, raw
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if (u.Scheme == "http" && u.Port() == "80") || (u.Scheme == "https" && u.Port() == "443") {
+		u.Host = u.Hostname()
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	pairs := queryPairs(u.RawQuery)
+	if kept := withoutTracking(pairs); len(kept) != len(pairs) {
+		pairs = kept
+		u.RawQuery = strings.Join(pairs, "&")
+	}
+	canonicalizeWikipedia(u)
+	clean = u.String()
+
+	host := strings.TrimPrefix(u.Host, "www.")
+	if strings.HasSuffix(host, ".wikipedia.org") {
+		host = strings.Replace(host, ".m.wikipedia.org", ".wikipedia.org", 1)
+	}
+	path := strings.TrimRight(u.EscapedPath(), "/")
+	canonical = "https://" + host + path
+	if u.RawQuery != "" {
+		sorted := queryPairs(u.RawQuery)
+		sort.Strings(sorted)
+		canonical += "?" + strings.Join(sorted, "&")
+	}
+	return clean, canonical
+}
+
+// queryPairs splits a raw query into its "&"-separated pairs, as written
+func queryPairs(rawQuery string) []string {
+	if rawQuery == "" {
+		return nil
+	}
+	return strings.Split(rawQuery, "&")
+}
+
+// withoutTracking drops the pairs whose name is a tracking parameter,
+// keeping the rest untouched and in order
+func withoutTracking(pairs []string) []string {
+	kept := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		name, _, _ := strings.Cut(pair, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if !isTrackingParam(name) {
+			kept = append(kept, pair)
+		}
+	}
+	return kept
+}
+
+// canonicalizeWikipedia rewrites index.php?title=X links to the /wiki/X form
+// and normalizes spaces in titles to underscores
+func canonicalizeWikipedia(u *url.URL) {
+	if !strings.HasSuffix(u.Hostname(), "wikipedia.org") {
+		return
+	}
+	if u.Path == "/w/index.php" {
+		if query, err := url.ParseQuery(u.RawQuery); err == nil && query.Get("title") != "" && len(query) == 1 {
+			u.Path = "/wiki/" + query.Get("title")
+			u.RawPath = ""
+			u.RawQuery = ""
+		}
+	}
+	if strings.HasPrefix(u.Path, "/wiki/") {
+		u.Path = strings.ReplaceAll(u.Path, " ", "_")
+		u.RawPath = ""
+	}
+}
+
+// canonicalizeResults normalizes every result URL and collapses duplicates,
+// keeping the first (best ranked) position and the best title and
+// description seen for it. Ranks are renumbered afterwards.
+func canonicalizeResults(results []SearchResult) []SearchResult {
+	out := make([]SearchResult, 0, len(results))
+	index := make(map[string]int)
+
+	for _, r := range results {
+		r.URL, r.CanonicalURL = canonicalizeURL(r.URL)
+		if i, ok := index[r.CanonicalURL]; ok {
+			mergeResult(&out[i], r)
+			continue
+		}
+		index[r.CanonicalURL] = len(out)
+		out = append(out, r)
+	}
+
+	for i := range out {
+		out[i].Rank = i + 1
+	}
+	return out
+}
+
+// mergeResult folds a duplicate into dst, preferring complete titles and
+// longer descriptions, and records every provider that returned it
+func mergeResult(dst *SearchResult, src SearchResult) {
+	if dst.Title == "" || (isTruncated(dst.Title) && !isTruncated(src.Title) && src.Title != "") {
+		dst.Title = src.Title
+	}
+	if len(src.Description) > len(dst.Description) {
+		dst.Description = src.Description
+	}
+	dst.Providers = appendUnique(dst.Providers, src.Providers...)
+}
+
+func isTruncated(s string) bool {
+	return strings.HasSuffix(s, "...") || strings.HasSuffix(s, "…")
+}
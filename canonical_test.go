@@ -0,0 +1,86 @@
+package main
+
+import "testing"
+
+func TestCanonicalizeURL(t *testing.T) {
+	tests := []struct {
+		raw       string
+		clean     string
+		canonical string
+	}{
+		{
+			raw:       "https://www.Example.com/docs/?utm_source=x&b=2&a=1#intro",
+			clean:     "https://www.example.com/docs/?b=2&a=1",
+			canonical: "https://example.com/docs?a=1&b=2",
+		},
+		{
+			// Without tracking parameters the query is left as written
+			raw:       "https://example.com/list?b=2&a=1",
+			clean:     "https://example.com/list?b=2&a=1",
+			canonical: "https://example.com/list?a=1&b=2",
+		},
+		{
+			raw:       "https://example.com/search?q",
+			clean:     "https://example.com/search?q",
+			canonical: "https://example.com/search?q",
+		},
+		{
+			// ";" does not parse as a query separator, so the query is kept
+			raw:       "https://example.com/p?a=1;b=2",
+			clean:     "https://example.com/p?a=1;b=2",
+			canonical: "https://example.com/p?a=1;b=2",
+		},
+		{
+			raw:       "https://example.com/p?a=1;b=2&utm_medium=email",
+			clean:     "https://example.com/p?a=1;b=2",
+			canonical: "https://example.com/p?a=1;b=2",
+		},
+		{
+			raw:       "http://example.com:80/docs",
+			clean:     "http://example.com/docs",
+			canonical: "https://example.com/docs",
+		},
+		{
+			raw:       "https://en.m.wikipedia.org/w/index.php?title=Go (programming language)",
+			clean:     "https://en.m.wikipedia.org/wiki/Go_%28programming_language%29",
+			canonical: "https://en.wikipedia.org/wiki/Go_%28programming_language%29",
+		},
+		{
+			raw:       "not a url",
+			clean:     "not a url",
+			canonical: "not a url",
+		},
+	}
+
+	for _, tt := range tests {
+		clean, canonical := canonicalizeURL(tt.raw)
+		if clean != tt.clean {
+			t.Errorf("canonicalizeURL(%q) clean = %q, want %q", tt.raw, clean, tt.clean)
+		}
+		if canonical != tt.canonical {
+			t.Errorf("canonicalizeURL(%q) canonical = %q, want %q", tt.raw, canonical, tt.canonical)
+		}
+	}
+}
+
+func TestCanonicalizeResults_Dedupes(t *testing.T) {
+	results := canonicalizeResults([]SearchResult{
+		{Title: "Example...", URL: "http://www.example.com/page/", Rank: 1, Providers: []string{"a"}},
+		{Title: "Other", URL: "https://other.example", Rank: 2, Providers: []string{"a"}},
+		{Title: "Example Domain", URL: "https://example.com/page?fbclid=123", Description: "longer", Rank: 3, Providers: []string{"b"}},
+	})
+
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results after dedupe, got %d", len(results))
+	}
+	first := results[0]
+	if first.Title != "Example Domain" || first.Description != "longer" {
+		t.Errorf("Expected best title and description to be kept, got %+v", first)
+	}
+	if len(first.Providers) != 2 {
+		t.Errorf("Expected both providers recorded, got %v", first.Providers)
+	}
+	if results[1].Rank != 2 {
+		t.Errorf("Expected ranks to be renumbered, got %d", results[1].Rank)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envDuration reads a Go duration (e.g. "10s", "1m") from the environment,
+// falling back to def when unset or invalid
+func envDuration(name string, def time.Duration) time.Duration {
+	v := strings.TrimSpace(os.Getenv(name))
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		log.Printf("Ignoring invalid %s=%q: expected a positive duration like 10s", name, v)
+		return def
+	}
+	return d
+}
+
+// envInt reads a positive integer from the environment, falling back to def
+// when unset or invalid
+func envInt(name string, def int) int {
+	v := strings.TrimSpace(os.Getenv(name))
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		log.Printf("Ignoring invalid %s=%q: expected a positive integer", name, v)
+		return def
+	}
+	return n
+}
+
+// envCount is envInt for settings where 0 is meaningful, such as turning a
+// feature off
+func envCount(name string, def int) int {
+	v := strings.TrimSpace(os.Getenv(name))
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		log.Printf("Ignoring invalid %s=%q: expected a non-negative integer", name, v)
+		return def
+	}
+	return n
+}
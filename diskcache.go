@@ -0,0 +1,362 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	diskCacheFileName = "search-cache.json"
+	diskCacheVersion  = 1
+
+	// A lock older than this is left over from a crashed process
+	staleLockAge = 30 * time.Second
+	lockTimeout  = 5 * time.Second
+)
+
+// diskCache persists search responses in a single JSON file so they survive
+// restarts. Several server processes may share it: writers serialize on a
+// lock file and replace the store atomically, so readers never need the lock.
+type diskCache struct {
+	path     string
+	ttl      time.Duration
+	maxBytes int64
+
+	// mu guards a parsed copy of the store, reused until the file's
+	// modification time or size changes so lookups do not re-read it
+	mu      sync.Mutex
+	parsed  *diskCacheStore
+	modTime time.Time
+	size    int64
+
+	// writing is held by the background write started by putAsync
+	writing sync.Mutex
+	pending sync.WaitGroup
+}
+
+type diskCacheStore struct {
+	Version int                       `json:"version"`
+	Entries map[string]diskCacheEntry `json:"entries"`
+}
+
+type diskCacheEntry struct {
+	Response *SearchResponse `json:"response"`
+	Stored   time.Time       `json:"stored"`
+	Expires  time.Time       `json:"expires"`
+}
+
+// DiskCacheInfo describes the store for `websearch-mcp cache info`
+type DiskCacheInfo struct {
+	Path    string `json:"path"`
+	Entries int    `json:"entries"`
+	Expired int    `json:"expired"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// defaultDiskCacheDir is websearch-mcp under the user cache directory
+// (e.g. ~/.cache, ~/Library/Caches or %LocalAppData%)
+func defaultDiskCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "websearch-mcp"), nil
+}
+
+// diskCacheFromEnv returns the disk cache configured by SEARCH_DISK_CACHE_DIR,
+// SEARCH_DISK_CACHE_TTL and SEARCH_DISK_CACHE_MAX_MB
+func diskCacheFromEnv() (*diskCache, error) {
+	dir := os.Getenv("SEARCH_DISK_CACHE_DIR")
+	if dir == "" {
+		var err error
+		if dir, err = defaultDiskCacheDir(); err != nil {
+			return nil, fmt.Errorf("no user cache directory: %w", err)
+		}
+	}
+	return &diskCache{
+		path:     filepath.Join(dir, diskCacheFileName),
+		ttl:      envDuration("SEARCH_DISK_CACHE_TTL", 24*time.Hour),
+		maxBytes: int64(envInt("SEARCH_DISK_CACHE_MAX_MB", 16)) << 20,
+	}, nil
+}
+
+func (c *diskCache) get(key string) (*SearchResponse, bool) {
+	store, err := c.current()
+	if err != nil {
+		return nil, false
+	}
+	entry, ok := store.Entries[key]
+	if !ok || time.Now().After(entry.Expires) || entry.Response == nil {
+		return nil, false
+	}
+	return entry.Response.clone(), true
+}
+
+// put stores res under key, dropping expired entries and then the oldest
+// ones until the store fits in maxBytes
+func (c *diskCache) put(key string, res *SearchResponse) error {
+	return c.withLock(func() error {
+		store, err := c.load()
+		if err != nil {
+			// Start over rather than fail forever on a corrupt file
+			store = &diskCacheStore{Entries: make(map[string]diskCacheEntry)}
+		}
+
+		now := time.Now()
+		for k, e := range store.Entries {
+			if now.After(e.Expires) {
+				delete(store.Entries, k)
+			}
+		}
+		store.Entries[key] = diskCacheEntry{Response: res, Stored: now, Expires: now.Add(c.ttl)}
+
+		data, err := json.Marshal(store)
+		if err != nil {
+			return err
+		}
+		if int64(len(data)) > c.maxBytes {
+			keys := make([]string, 0, len(store.Entries))
+			for k := range store.Entries {
+				keys = append(keys, k)
+			}
+			sort.Slice(keys, func(i, j int) bool {
+				return store.Entries[keys[i]].Stored.Before(store.Entries[keys[j]].Stored)
+			})
+			for _, k := range keys {
+				if int64(len(data)) <= c.maxBytes {
+					break
+				}
+				delete(store.Entries, k)
+				if data, err = json.Marshal(store); err != nil {
+					return err
+				}
+			}
+		}
+		if err := c.write(data); err != nil {
+			return err
+		}
+		c.remember(store)
+		return nil
+	})
+}
+
+// putAsync stores res in the background so the cache file never delays a
+// response. While an earlier write is still running the entry is skipped
+// instead of queued; it is in the memory cache either way.
+func (c *diskCache) putAsync(key string, res *SearchResponse, logf func(string, ...interface{})) {
+	if !c.writing.TryLock() {
+		return
+	}
+	c.pending.Add(1)
+	go func() {
+		defer c.pending.Done()
+		defer c.writing.Unlock()
+		if err := c.put(key, res); err != nil {
+			logf("Error writing disk cache: %v", err)
+		}
+	}()
+}
+
+// flush waits for the background write, if any
+func (c *diskCache) flush() {
+	c.pending.Wait()
+}
+
+// info reports on the store without changing it
+func (c *diskCache) info() (DiskCacheInfo, error) {
+	info := DiskCacheInfo{Path: c.path}
+	st, err := os.Stat(c.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return info, nil
+	}
+	if err != nil {
+		return info, err
+	}
+	info.Bytes = st.Size()
+
+	store, err := c.load()
+	if err != nil {
+		return info, err
+	}
+	now := time.Now()
+	for _, e := range store.Entries {
+		info.Entries++
+		if now.After(e.Expires) {
+			info.Expired++
+		}
+	}
+	return info, nil
+}
+
+// purge deletes the store
+func (c *diskCache) purge() error {
+	return c.withLock(func() error {
+		err := os.Remove(c.path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	})
+}
+
+// current returns the store for reading, parsing the file again only when
+// it has changed since it was last parsed. The result is shared and must not
+// be modified.
+func (c *diskCache) current() (*diskCacheStore, error) {
+	st, err := os.Stat(c.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c.load()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.parsed != nil && st.ModTime().Equal(c.modTime) && st.Size() == c.size {
+		return c.parsed, nil
+	}
+	store, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	c.parsed, c.modTime, c.size = store, st.ModTime(), st.Size()
+	return store, nil
+}
+
+// remember keeps the store just written as the parsed copy, so the next
+// lookup does not read back our own write
+func (c *diskCache) remember(store *diskCacheStore) {
+	st, err := os.Stat(c.path)
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.parsed, c.modTime, c.size = store, st.ModTime(), st.Size()
+}
+
+func (c *diskCache) load() (*diskCacheStore, error) {
+	data, err := os.ReadFile(c.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &diskCacheStore{Version: diskCacheVersion, Entries: make(map[string]diskCacheEntry)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var store diskCacheStore
+	if err := json.Unmarshal(data, &store); err != nil {
+		return nil, fmt.Errorf("corrupt cache file %s: %w", c.path, err)
+	}
+	if store.Version != diskCacheVersion || store.Entries == nil {
+		store = diskCacheStore{Entries: make(map[string]diskCacheEntry)}
+	}
+	store.Version = diskCacheVersion
+	return &store, nil
+}
+
+// write replaces the store atomically: readers see either the old or the new
+// file, never a partial one
+func (c *diskCache) write(data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), diskCacheFileName+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
+
+// withLock runs fn while holding the store's lock file. The lock is a file
+// created with O_EXCL, which works the same on every platform; a lock left
+// behind by a crashed process is taken over once it is stale.
+func (c *diskCache) withLock(fn func() error) error {
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	lockPath := c.path + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			f.Close()
+			break
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+		if st, err := os.Stat(lockPath); err == nil && time.Since(st.ModTime()) > staleLockAge {
+			breakStaleLock(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for cache lock %s", lockPath)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer os.Remove(lockPath)
+	return fn()
+}
+
+// breakStaleLock removes a lock found to be stale. Removing it by name could
+// delete a fresh lock that another process created after it removed the stale
+// one, so the lock is first renamed aside, which only one process can do,
+// and its age is checked again on the renamed file. A fresh lock moved by
+// mistake is put back unless a new one has been created meanwhile.
+func breakStaleLock(lockPath string) {
+	aside := fmt.Sprintf("%s.%d-%d.stale", lockPath, os.Getpid(), time.Now().UnixNano())
+	if err := os.Rename(lockPath, aside); err != nil {
+		return
+	}
+	if st, err := os.Stat(aside); err == nil && time.Since(st.ModTime()) <= staleLockAge {
+		// Link fails if lockPath exists, so a newer lock is never replaced
+		os.Link(aside, lockPath)
+	}
+	os.Remove(aside)
+}
+
+// runCacheCommand implements `websearch-mcp cache [info|purge]`
+func runCacheCommand(args []string) error {
+	cache, err := diskCacheFromEnv()
+	if err != nil {
+		return err
+	}
+
+	cmd := "info"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+	switch cmd {
+	case "info":
+		info, err := cache.info()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Cache file: %s\n", info.Path)
+		fmt.Printf("  Entries: %d (%d expired)\n", info.Entries, info.Expired)
+		fmt.Printf("  Size: %d bytes\n", info.Bytes)
+		return nil
+	case "purge":
+		if err := cache.purge(); err != nil {
+			return err
+		}
+		fmt.Printf("Purged %s\n", cache.path)
+		return nil
+	default:
+		return fmt.Errorf("unknown cache command %q: expected info or purge", cmd)
+	}
+}
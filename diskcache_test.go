@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestDiskCache(t *testing.T) *diskCache {
+	t.Helper()
+	return &diskCache{
+		path:     filepath.Join(t.TempDir(), diskCacheFileName),
+		ttl:      time.Hour,
+		maxBytes: 1 << 20,
+	}
+}
+
+func TestDiskCache_PutGetPurge(t *testing.T) {
+	c := newTestDiskCache(t)
+	if _, ok := c.get("k"); ok {
+		t.Fatal("Expected a miss on an empty cache")
+	}
+
+	res := &SearchResponse{Query: "go", Results: []SearchResult{{Title: "Go", URL: "https://go.dev", Rank: 1}}, Count: 1}
+	if err := c.put("k", res); err != nil {
+		t.Fatal(err)
+	}
+	got, ok := c.get("k")
+	if !ok || got.Count != 1 || got.Results[0].URL != "https://go.dev" {
+		t.Errorf("Expected the stored response back, got %+v", got)
+	}
+	if _, err := os.Stat(c.path + ".lock"); !os.IsNotExist(err) {
+		t.Error("Expected the lock file to be released")
+	}
+
+	info, err := c.info()
+	if err != nil || info.Entries != 1 || info.Bytes == 0 {
+		t.Errorf("Unexpected info %+v %v", info, err)
+	}
+
+	if err := c.purge(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.get("k"); ok {
+		t.Error("Expected a miss after purge")
+	}
+}
+
+func TestDiskCache_TTLAndSizeEviction(t *testing.T) {
+	c := newTestDiskCache(t)
+	c.ttl = -time.Second
+	c.put("expired", &SearchResponse{Query: "old"})
+	if _, ok := c.get("expired"); ok {
+		t.Error("Expected expired entries to miss")
+	}
+
+	c.ttl = time.Hour
+	c.maxBytes = 600
+	long := strings.Repeat("x", 200)
+	for _, k := range []string{"a", "b", "c"} {
+		if err := c.put(k, &SearchResponse{Query: long}); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, ok := c.get("a"); ok {
+		t.Error("Expected the oldest entry to be evicted to fit the size limit")
+	}
+	if _, ok := c.get("c"); !ok {
+		t.Error("Expected the newest entry to be kept")
+	}
+	if info, _ := c.info(); info.Bytes > c.maxBytes || info.Expired != 0 {
+		t.Errorf("Expected a pruned store within the size limit, got %+v", info)
+	}
+}
+
+func TestDiskCache_ConcurrentWriters(t *testing.T) {
+	dir := t.TempDir()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Separate instances, as separate processes would have
+			c := &diskCache{path: filepath.Join(dir, diskCacheFileName), ttl: time.Hour, maxBytes: 1 << 20}
+			if err := c.put(string(rune('a'+i)), &SearchResponse{Query: "q"}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	c := &diskCache{path: filepath.Join(dir, diskCacheFileName), ttl: time.Hour, maxBytes: 1 << 20}
+	if info, err := c.info(); err != nil || info.Entries != 8 {
+		t.Errorf("Expected all 8 writes to survive, got %+v %v", info, err)
+	}
+}
+
+func TestDiskCache_StaleLock(t *testing.T) {
+	c := newTestDiskCache(t)
+	lock := c.path + ".lock"
+	if err := os.WriteFile(lock, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * staleLockAge)
+	os.Chtimes(lock, old, old)
+
+	if err := c.put("k", &SearchResponse{Query: "q"}); err != nil {
+		t.Errorf("Expected a stale lock to be taken over, got %v", err)
+	}
+}
+
+func TestBreakStaleLock_KeepsFreshLock(t *testing.T) {
+	c := newTestDiskCache(t)
+	lock := c.path + ".lock"
+	if err := os.MkdirAll(filepath.Dir(lock), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// Another process replaced the stale lock after this one saw it
+	if err := os.WriteFile(lock, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	breakStaleLock(lock)
+	if _, err := os.Stat(lock); err != nil {
+		t.Errorf("Expected the fresh lock to be put back, got %v", err)
+	}
+	if leftovers, _ := filepath.Glob(lock + ".*.stale"); len(leftovers) != 0 {
+		t.Errorf("Expected no renamed locks left behind, got %v", leftovers)
+	}
+}
+
+func TestWebSearchServer_DiskCacheSkipsDegradedAnswers(t *testing.T) {
+	disk := newTestDiskCache(t)
+	failing := &stubProvider{name: "first", err: newProviderError("first", ErrNetwork, errors.New("connection reset"))}
+	fallback := &stubProvider{name: "second", results: []SearchResult{{Title: "Hit", URL: "https://example.com", Rank: 1}}}
+
+	server := newTestServer(failing, fallback)
+	server.disk = disk
+	if _, err := server.performWebSearch(context.Background(), "q", 5); err != nil {
+		t.Fatal(err)
+	}
+	disk.flush()
+
+	if _, ok := disk.get(cacheKey("", server.providers.Enabled(), Query{Text: "q", MaxResults: 5})); ok {
+		t.Error("Expected a degraded answer to stay out of the disk cache")
+	}
+}
+
+func TestWebSearchServer_DiskCacheSurvivesRestart(t *testing.T) {
+	disk := newTestDiskCache(t)
+	p := &stubProvider{name: "stub", results: []SearchResult{{Title: "Hit", URL: "https://example.com", Rank: 1}}}
+
+	first := newTestServer(p)
+	first.disk = disk
+	if _, err := first.performWebSearch(context.Background(), "q", 5); err != nil {
+		t.Fatal(err)
+	}
+	disk.flush()
+
+	second := newTestServer(p)
+	second.disk = disk
+	if res, err := second.performWebSearch(context.Background(), "q", 5); err != nil || res.Count != 1 {
+		t.Fatalf("Expected a disk cache hit, got %v %v", res, err)
+	}
+	if p.calls != 1 {
+		t.Errorf("Expected one upstream search across both servers, got %d", p.calls)
+	}
+	if second.stats.GetStats()["cache"].(map[string]interface{})["disk_hits"] != int64(1) {
+		t.Error("Expected the disk hit to be counted")
+	}
+}
+
+func TestDiskCache_ReusesParsedStore(t *testing.T) {
+	c := newTestDiskCache(t)
+	res := &SearchResponse{Query: "go", Results: []SearchResult{{Title: "Go", URL: "https://go.dev", Rank: 1}}, Count: 1}
+	if err := c.put("k", res); err != nil {
+		t.Fatal(err)
+	}
+	st, err := os.Stat(c.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Garbage of the same size and modification time is never read
+	if err := os.WriteFile(c.path, []byte(strings.Repeat("x", int(st.Size()))), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(c.path, st.ModTime(), st.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.get("k"); !ok {
+		t.Fatal("Expected the parsed store to be reused while the file looks unchanged")
+	}
+
+	// Once the file changes it is read again
+	later := st.ModTime().Add(time.Second)
+	if err := os.Chtimes(c.path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.get("k"); ok {
+		t.Error("Expected the changed (corrupt) file to be re-read")
+	}
+}
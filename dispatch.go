@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// dispatcher runs requests concurrently, up to a limit, and funnels every
+// response through a single writer goroutine so responses can go out in
+// whatever order they finish without interleaving on the wire
+type dispatcher struct {
+	server   *WebSearchServer
+	sem      chan struct{}
+	out      chan interface{}
+	writeErr chan error
+	wg       sync.WaitGroup
+	done     chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// newDispatcher starts the writer goroutine. write is only ever called from
+// that goroutine, with either a *MCPMessage or a batch reply. After the first
+// write error the remaining responses are discarded and the error is reported
+// on errs().
+func (s *WebSearchServer) newDispatcher(limit int, write func(interface{}) error) *dispatcher {
+	if limit <= 0 {
+		limit = 1
+	}
+	d := &dispatcher{
+		server:   s,
+		sem:      make(chan struct{}, limit),
+		out:      make(chan interface{}),
+		writeErr: make(chan error, 1),
+		done:     make(chan struct{}),
+	}
+
+	go func() {
+		defer close(d.done)
+		failed := false
+		for response := range d.out {
+			if failed {
+				continue
+			}
+			if err := write(response); err != nil {
+				s.logger.Printf("Error encoding response: %v", err)
+				s.stats.IncrementErrors()
+				failed = true
+				d.writeErr <- err
+			}
+		}
+	}()
+	return d
+}
+
+// begin registers a unit of work, or reports false once wait has been called;
+// transports whose reads race with shutdown (like legacy SSE POSTs) rely on
+// this to drop late messages instead of writing to a closed channel
+func (d *dispatcher) begin() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return false
+	}
+	d.wg.Add(1)
+	return true
+}
+
+// dispatchPayload decodes one payload read off the wire, a single message or
+// a batch, and dispatches it. Undecodable payloads are answered with a
+// JSON-RPC error.
+func (d *dispatcher) dispatchPayload(ctx context.Context, data []byte) {
+	if isBatch(data) {
+		d.dispatchBatch(ctx, data)
+		return
+	}
+
+	msg, errResponse := decodeMessage(data)
+	if errResponse != nil {
+		d.server.logger.Printf("Error unmarshaling message: %v", errResponse.Error.Data)
+		d.server.stats.IncrementErrors()
+		d.reply(errResponse)
+		return
+	}
+
+	d.server.stats.IncrementRequests()
+	d.dispatch(ctx, msg)
+}
+
+// dispatch handles msg in the background. Notifications are handled inline,
+// so a cancellation is never stuck behind the requests it is meant to stop,
+// and so is initialize, so that requests sent right after it see the
+// initialized session.
+func (d *dispatcher) dispatch(ctx context.Context, msg MCPMessage) {
+	if !d.begin() {
+		return
+	}
+	if msg.isNotification() || msg.isResponse() {
+		defer d.wg.Done()
+		d.server.handleMessage(ctx, msg)
+		return
+	}
+	if msg.Method == "initialize" {
+		defer d.wg.Done()
+		if response := d.server.handleMessage(ctx, msg); response != nil {
+			d.out <- response
+		}
+		return
+	}
+
+	// Register the request before it queues, so a cancellation sent while it
+	// waits for a slot stops it
+	reqCtx, finish := d.server.inFlightFor(ctx).track(ctx, msg.ID)
+	go func() {
+		defer d.wg.Done()
+		if response := d.server.handleQueued(reqCtx, d.sem, msg, finish); response != nil {
+			d.out <- response
+		}
+	}()
+}
+
+// handleQueued waits for a slot in sem and handles msg, a request registered
+// with track. It returns nil when the request was cancelled, whether while
+// queued or while running, or when ctx ended before a slot was free.
+func (s *WebSearchServer) handleQueued(ctx context.Context, sem chan struct{}, msg MCPMessage, finish func() bool) *MCPMessage {
+	select {
+	case sem <- struct{}{}:
+		defer func() { <-sem }()
+	case <-ctx.Done():
+		finish()
+		return nil
+	}
+
+	response := s.handleMessage(ctx, msg)
+	if finish() {
+		// The client cancelled the request; the spec says not to respond
+		return nil
+	}
+	return response
+}
+
+// dispatchBatch handles a JSON-RPC batch in the background and writes its
+// replies as one array. Its requests take slots from the same semaphore as
+// single requests.
+func (d *dispatcher) dispatchBatch(ctx context.Context, data []byte) {
+	if !d.begin() {
+		return
+	}
+	go func() {
+		defer d.wg.Done()
+		if reply := d.server.handleBatch(ctx, data, d.sem); reply != nil {
+			d.out <- reply
+		}
+	}()
+}
+
+// reply queues a response produced outside handleMessage, such as a parse
+// error
+func (d *dispatcher) reply(response *MCPMessage) {
+	if !d.begin() {
+		return
+	}
+	defer d.wg.Done()
+	d.out <- response
+}
+
+// errs reports the first write error
+func (d *dispatcher) errs() <-chan error {
+	return d.writeErr
+}
+
+// wait blocks until every dispatched request has been answered and written
+func (d *dispatcher) wait() {
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+
+	d.wg.Wait()
+	close(d.out)
+	<-d.done
+}
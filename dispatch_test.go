@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDispatcher_SlowRequestDoesNotBlockOthers(t *testing.T) {
+	server := newTestServer(&blockingProvider{name: "slow"})
+
+	written := make(chan *MCPMessage, 2)
+	d := server.newDispatcher(4, func(response interface{}) error {
+		written <- response.(*MCPMessage)
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d.dispatch(ctx, MCPMessage{
+		JSONRPC: "2.0",
+		ID:      float64(1),
+		Method:  "tools/call",
+		Params: map[string]interface{}{
+			"name":      "web_search",
+			"arguments": map[string]interface{}{"query": "golang"},
+		},
+	})
+	d.dispatch(ctx, MCPMessage{JSONRPC: "2.0", ID: float64(2), Method: "ping"})
+
+	select {
+	case response := <-written:
+		if response.ID != float64(2) {
+			t.Errorf("Expected ping (id 2) to be answered first, got id %v", response.ID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Ping was blocked behind the slow search")
+	}
+
+	cancel()
+	d.wait()
+}
+
+func TestDispatcher_CancelQueuedRequest(t *testing.T) {
+	server := newTestServer(&blockingProvider{name: "slow"})
+
+	written := make(chan *MCPMessage, 2)
+	d := server.newDispatcher(1, func(response interface{}) error {
+		written <- response.(*MCPMessage)
+		return nil
+	})
+
+	search := func(id float64) MCPMessage {
+		return MCPMessage{
+			JSONRPC: "2.0",
+			ID:      id,
+			Method:  "tools/call",
+			Params: map[string]interface{}{
+				"name":      "web_search",
+				"arguments": map[string]interface{}{"query": "golang"},
+			},
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d.dispatch(ctx, search(1))
+	d.dispatch(ctx, search(2))
+	d.dispatch(ctx, MCPMessage{
+		JSONRPC: "2.0",
+		Method:  "notifications/cancelled",
+		Params:  map[string]interface{}{"requestId": float64(2)},
+	})
+
+	// id 1 still holds the only slot, so id 2 was cancelled while queued
+	select {
+	case response := <-written:
+		t.Fatalf("Expected no response while id 1 runs, got id %v", response.ID)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cancel()
+	d.wait()
+	close(written)
+	for response := range written {
+		if response.ID == float64(2) {
+			t.Errorf("Expected no response for the cancelled request, got %+v", response)
+		}
+	}
+}
@@ -4,10 +4,10 @@ go 1.21
 
 require (
 	github.com/PuerkitoBio/goquery v1.8.1
+	github.com/andybalholm/cascadia v1.3.1
 	github.com/gorilla/websocket v1.5.3
 )
 
 require (
-	github.com/andybalholm/cascadia v1.3.1 // indirect
 	golang.org/x/net v0.7.0 // indirect
 )
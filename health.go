@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// healthWindow is how many recent calls the success rate and latency cover
+const healthWindow = 20
+
+type breakerState string
+
+const (
+	breakerClosed   breakerState = "closed"
+	breakerOpen     breakerState = "open"
+	breakerHalfOpen breakerState = "half_open"
+)
+
+var errCircuitOpen = errors.New("skipped while the provider recovers from repeated failures")
+
+// ProviderHealth is the reported health of one provider
+type ProviderHealth struct {
+	State               breakerState `json:"state"`
+	SuccessRate         float64      `json:"success_rate"`
+	AvgLatencyMS        int64        `json:"avg_latency_ms"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	Calls               int          `json:"calls"`
+	OpenUntil           *time.Time   `json:"open_until,omitempty"`
+}
+
+type callOutcome struct {
+	ok      bool
+	latency time.Duration
+}
+
+type providerHealth struct {
+	recent              []callOutcome
+	consecutiveFailures int
+	state               breakerState
+	openedAt            time.Time
+	probing             bool
+}
+
+// healthTracker keeps a rolling health record per provider and runs a
+// circuit breaker on it: after threshold consecutive failures a provider is
+// skipped for cooldown, then a single probe call decides whether it is back.
+type healthTracker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	providers map[string]*providerHealth
+}
+
+func newHealthTracker(threshold int, cooldown time.Duration) *healthTracker {
+	return &healthTracker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		providers: make(map[string]*providerHealth),
+	}
+}
+
+func (h *healthTracker) get(name string) *providerHealth {
+	ph, ok := h.providers[name]
+	if !ok {
+		ph = &providerHealth{state: breakerClosed}
+		h.providers[name] = ph
+	}
+	return ph
+}
+
+// available reports, without changing state, whether a call to name would be
+// let through
+func (h *healthTracker) available(name string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ph := h.get(name)
+	switch ph.state {
+	case breakerOpen:
+		return time.Since(ph.openedAt) >= h.cooldown
+	case breakerHalfOpen:
+		return !ph.probing
+	}
+	return true
+}
+
+// acquire lets a call to name through, turning an open breaker whose
+// cooldown has passed into a half-open one with this call as its probe
+func (h *healthTracker) acquire(name string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ph := h.get(name)
+	switch ph.state {
+	case breakerOpen:
+		if time.Since(ph.openedAt) < h.cooldown {
+			return false
+		}
+		ph.state = breakerHalfOpen
+		ph.probing = true
+	case breakerHalfOpen:
+		if ph.probing {
+			return false
+		}
+		ph.probing = true
+	}
+	return true
+}
+
+// record closes out a call acquired for name
+func (h *healthTracker) record(name string, ok bool, latency time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ph := h.get(name)
+	ph.probing = false
+
+	ph.recent = append(ph.recent, callOutcome{ok: ok, latency: latency})
+	if len(ph.recent) > healthWindow {
+		ph.recent = ph.recent[len(ph.recent)-healthWindow:]
+	}
+
+	if ok {
+		ph.consecutiveFailures = 0
+		ph.state = breakerClosed
+		return
+	}
+	ph.consecutiveFailures++
+	if ph.state == breakerHalfOpen || ph.consecutiveFailures >= h.threshold {
+		ph.state = breakerOpen
+		ph.openedAt = time.Now()
+	}
+}
+
+// release gives up a call that ended without telling us anything about the
+// provider, such as one cancelled by the client
+func (h *healthTracker) release(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.get(name).probing = false
+}
+
+// snapshot reports the health of every provider in names
+func (h *healthTracker) snapshot(names []string) map[string]ProviderHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	out := make(map[string]ProviderHealth, len(names))
+	for _, name := range names {
+		ph := h.get(name)
+		report := ProviderHealth{
+			State:               ph.state,
+			SuccessRate:         1,
+			ConsecutiveFailures: ph.consecutiveFailures,
+			Calls:               len(ph.recent),
+		}
+		if len(ph.recent) > 0 {
+			var ok int
+			var total time.Duration
+			for _, o := range ph.recent {
+				if o.ok {
+					ok++
+				}
+				total += o.latency
+			}
+			report.SuccessRate = float64(ok) / float64(len(ph.recent))
+			report.AvgLatencyMS = (total / time.Duration(len(ph.recent))).Milliseconds()
+		}
+		if ph.state == breakerOpen {
+			until := ph.openedAt.Add(h.cooldown)
+			report.OpenUntil = &until
+		}
+		out[name] = report
+	}
+	return out
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthTracker_Breaker(t *testing.T) {
+	h := newHealthTracker(2, 20*time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		if !h.acquire("p") {
+			t.Fatalf("Call %d: expected closed breaker to allow calls", i+1)
+		}
+		h.record("p", false, time.Millisecond)
+	}
+	if h.acquire("p") || h.available("p") {
+		t.Fatal("Expected breaker to open after consecutive failures")
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	if !h.acquire("p") {
+		t.Fatal("Expected a probe after the cooldown")
+	}
+	if h.acquire("p") {
+		t.Error("Expected only one probe while half-open")
+	}
+	h.record("p", true, time.Millisecond)
+
+	report := h.snapshot([]string{"p"})["p"]
+	if report.State != breakerClosed || report.ConsecutiveFailures != 0 || report.Calls != 3 {
+		t.Errorf("Expected closed breaker after a successful probe, got %+v", report)
+	}
+	if report.SuccessRate < 0.33 || report.SuccessRate > 0.34 {
+		t.Errorf("Expected success rate 1/3, got %v", report.SuccessRate)
+	}
+}
+
+func TestWebSearchServer_SkipsTrippedProvider(t *testing.T) {
+	failing := &stubProvider{name: "failing", err: errors.New("timeout")}
+	good := &stubProvider{name: "good", results: []SearchResult{{Title: "Hit", URL: "https://example.com", Rank: 1}}}
+	server := newTestServer(failing, good)
+	server.health = newHealthTracker(2, time.Hour)
+
+	for i := 0; i < 4; i++ {
+		if _, err := server.performWebSearch(context.Background(), "q", 5); err != nil {
+			t.Fatalf("Search %d: expected fallback to succeed, got %v", i+1, err)
+		}
+	}
+	if failing.calls != 2 {
+		t.Errorf("Expected the failing provider to be skipped once tripped, got %d calls", failing.calls)
+	}
+
+	rr := httptest.NewRecorder()
+	server.httpHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var health struct {
+		Status    string                    `json:"status"`
+		Providers map[string]ProviderHealth `json:"providers"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&health); err != nil {
+		t.Fatal(err)
+	}
+	if health.Status != "degraded" || health.Providers["failing"].State != breakerOpen {
+		t.Errorf("Expected degraded health with an open breaker, got %+v", health)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// inFlightRequests tracks running requests by JSON-RPC ID so a
+// notifications/cancelled from the client can abort them
+type inFlightRequests struct {
+	mu       sync.Mutex
+	requests map[string]*inFlightRequest
+}
+
+type inFlightRequest struct {
+	key       string
+	cancel    context.CancelFunc
+	cancelled bool
+}
+
+// inFlightKey is the context key under which track stores the request it
+// registered
+type inFlightKey struct{}
+
+func newInFlightRequests() *inFlightRequests {
+	return &inFlightRequests{requests: make(map[string]*inFlightRequest)}
+}
+
+// requestKey turns a JSON-RPC ID into a map key. The JSON encoding keeps the
+// string "1" distinct from the number 1.
+func requestKey(id interface{}) string {
+	b, err := json.Marshal(id)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// track registers id and returns a context that cancel(id) aborts. The
+// returned finish func must be called when the request completes; it reports
+// whether the client cancelled the request, in which case the response must
+// not be sent. Tracking a request that ctx is already tracking, as the
+// dispatcher does before a request queues, reuses the existing registration.
+func (r *inFlightRequests) track(ctx context.Context, id interface{}) (context.Context, func() bool) {
+	key := requestKey(id)
+	if req, ok := ctx.Value(inFlightKey{}).(*inFlightRequest); ok && req.key == key {
+		return ctx, func() bool {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			return req.cancelled
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	req := &inFlightRequest{key: key, cancel: cancel}
+	ctx = context.WithValue(ctx, inFlightKey{}, req)
+
+	r.mu.Lock()
+	r.requests[key] = req
+	r.mu.Unlock()
+
+	return ctx, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.requests[key] == req {
+			delete(r.requests, key)
+		}
+		cancel()
+		return req.cancelled
+	}
+}
+
+// cancel aborts the request with the given ID. It reports false when no such
+// request is running, e.g. because it already finished.
+func (r *inFlightRequests) cancel(id interface{}) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	req, ok := r.requests[requestKey(id)]
+	if !ok {
+		return false
+	}
+	req.cancelled = true
+	req.cancel()
+	return true
+}
+
+// inFlightFor returns the tracker for the session ctx belongs to. Messages
+// handled outside a transport share the server-wide tracker.
+func (s *WebSearchServer) inFlightFor(ctx context.Context) *inFlightRequests {
+	if sess := sessionFromContext(ctx); sess != nil {
+		return sess.inFlight
+	}
+	return s.inFlight
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWebSearchServer_CancelledToolCall(t *testing.T) {
+	server := newTestServer(&blockingProvider{name: "slow"})
+
+	call := MCPMessage{
+		JSONRPC: "2.0",
+		ID:      float64(7),
+		Method:  "tools/call",
+		Params: map[string]interface{}{
+			"name":      "web_search",
+			"arguments": map[string]interface{}{"query": "golang"},
+		},
+	}
+	done := make(chan *MCPMessage, 1)
+	go func() {
+		done <- server.handleMessage(context.Background(), call)
+	}()
+
+	// The call registers itself asynchronously; retry until it is tracked
+	deadline := time.Now().Add(2 * time.Second)
+	for !server.inFlight.cancel(float64(7)) {
+		if time.Now().After(deadline) {
+			t.Fatal("Tool call was never tracked as in flight")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	select {
+	case response := <-done:
+		if response != nil {
+			t.Errorf("Expected no response for a cancelled request, got %+v", response)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Tool call did not return after cancellation")
+	}
+
+	notification := MCPMessage{
+		JSONRPC: "2.0",
+		Method:  "notifications/cancelled",
+		Params:  map[string]interface{}{"requestId": float64(7), "reason": "user pressed stop"},
+	}
+	if response := server.handleMessage(context.Background(), notification); response != nil {
+		t.Errorf("Expected no response to a cancellation notification, got %+v", response)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+)
+
+// UnmarshalJSON records whether the "id" member was present at all, which is
+// what separates a notification from a request with a null ID
+func (m *MCPMessage) UnmarshalJSON(data []byte) error {
+	type plain MCPMessage
+	var raw struct {
+		plain
+		ID json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*m = MCPMessage(raw.plain)
+	if raw.ID != nil {
+		m.hasID = true
+		if err := json.Unmarshal(raw.ID, &m.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalJSON always writes "id" on responses; JSON-RPC requires it, as null,
+// even when the request's ID could not be determined
+func (m MCPMessage) MarshalJSON() ([]byte, error) {
+	type plain MCPMessage
+	if m.Method == "" && m.ID == nil {
+		return json.Marshal(struct {
+			JSONRPC string      `json:"jsonrpc"`
+			ID      interface{} `json:"id"`
+			Result  interface{} `json:"result,omitempty"`
+			Error   *MCPError   `json:"error,omitempty"`
+		}{m.JSONRPC, nil, m.Result, m.Error})
+	}
+	return json.Marshal(plain(m))
+}
+
+// isNotification reports whether msg is a notification, which must never be
+// answered
+func (m MCPMessage) isNotification() bool {
+	return m.Method != "" && m.ID == nil && !m.hasID
+}
+
+// isResponse reports whether msg is a response from the client
+func (m MCPMessage) isResponse() bool {
+	return m.Method == "" && (m.Result != nil || m.Error != nil)
+}
+
+// validID reports whether a request ID is a string or number as required by
+// JSON-RPC; MCP additionally forbids null
+func validID(id interface{}) bool {
+	switch id.(type) {
+	case string, float64, int, int64:
+		return true
+	}
+	return false
+}
+
+// decodeMessage parses one JSON-RPC message. On failure it returns the error
+// response to send: -32700 for malformed JSON, -32600 for valid JSON that is
+// not a message object.
+func decodeMessage(data []byte) (MCPMessage, *MCPMessage) {
+	var msg MCPMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		response := &MCPMessage{
+			JSONRPC: "2.0",
+			Error: &MCPError{
+				Code:    -32700,
+				Message: "Parse error",
+				Data:    err.Error(),
+			},
+		}
+		if json.Valid(data) {
+			response.Error = &MCPError{
+				Code:    -32600,
+				Message: "Invalid Request",
+				Data:    "message must be a JSON object",
+			}
+		}
+		return msg, response
+	}
+	return msg, nil
+}
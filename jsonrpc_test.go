@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestDecodeMessage_NotificationVersusNullID(t *testing.T) {
+	notification, errResponse := decodeMessage([]byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`))
+	if errResponse != nil {
+		t.Fatalf("Expected no error, got: %v", errResponse.Error)
+	}
+	if !notification.isNotification() {
+		t.Error("Expected message without id to be a notification")
+	}
+
+	nullID, errResponse := decodeMessage([]byte(`{"jsonrpc":"2.0","id":null,"method":"ping"}`))
+	if errResponse != nil {
+		t.Fatalf("Expected no error, got: %v", errResponse.Error)
+	}
+	if nullID.isNotification() {
+		t.Error("Expected message with id null not to be a notification")
+	}
+
+	server := NewWebSearchServer()
+	if response := server.handleMessage(context.Background(), notification); response != nil {
+		t.Errorf("Expected no response to a notification, got %+v", response)
+	}
+	response := server.handleMessage(context.Background(), nullID)
+	if response == nil || response.Error == nil || response.Error.Code != -32600 {
+		t.Errorf("Expected -32600 for a null id, got %+v", response)
+	}
+}
+
+func TestDecodeMessage_ParseError(t *testing.T) {
+	_, errResponse := decodeMessage([]byte(`{"jsonrpc":`))
+	if errResponse == nil || errResponse.Error.Code != -32700 {
+		t.Fatalf("Expected -32700 parse error, got %+v", errResponse)
+	}
+
+	encoded, err := json.Marshal(errResponse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if id, ok := decoded["id"]; !ok || id != nil {
+		t.Errorf("Expected parse error response to carry \"id\": null, got %s", encoded)
+	}
+}
+
+func TestWebSearchServer_ToolsCallBeforeInitialize(t *testing.T) {
+	server := NewWebSearchServer()
+	ctx := withSession(context.Background(), NewSession())
+
+	call := MCPMessage{
+		JSONRPC: "2.0",
+		ID:      1,
+		Method:  "tools/call",
+		Params: map[string]interface{}{
+			"name":      "unknown_tool",
+			"arguments": map[string]interface{}{},
+		},
+	}
+	response := server.handleMessage(ctx, call)
+	if response == nil || response.Error == nil || response.Error.Code != -32002 {
+		t.Fatalf("Expected -32002 before initialize, got %+v", response)
+	}
+
+	server.handleMessage(ctx, MCPMessage{JSONRPC: "2.0", ID: 2, Method: "initialize"})
+
+	response = server.handleMessage(ctx, call)
+	if response == nil || response.Error == nil || response.Error.Code != -32601 {
+		t.Errorf("Expected the call to reach tool dispatch after initialize, got %+v", response)
+	}
+}
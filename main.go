@@ -7,8 +7,8 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
-	"net/url"
 	"os"
 	"os/signal"
 	"runtime"
@@ -16,8 +16,6 @@ import (
 	"sync"
 	"syscall"
 	"time"
-
-	"github.com/PuerkitoBio/goquery"
 )
 
 // Build-time variables (set via ldflags)
@@ -57,6 +55,14 @@ type ServerStats struct {
 	ConnectionCount int64     `json:"connection_count"`
 	ActiveConns     int64     `json:"active_connections"`
 	Errors          int64     `json:"errors"`
+	// ProviderErrors counts provider failures by kind
+	ProviderErrors map[ErrorKind]int64 `json:"provider_errors"`
+	CacheHits      int64               `json:"cache_hits"`
+	DiskCacheHits  int64               `json:"disk_cache_hits"`
+	CacheMisses    int64               `json:"cache_misses"`
+	// CoalescedSearches counts cache misses that joined an identical search
+	// already in flight instead of starting their own
+	CoalescedSearches int64 `json:"coalesced_searches"`
 }
 
 func (s *ServerStats) IncrementRequests() {
@@ -90,6 +96,39 @@ func (s *ServerStats) IncrementErrors() {
 	s.Errors++
 }
 
+func (s *ServerStats) IncrementProviderError(kind ErrorKind) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ProviderErrors == nil {
+		s.ProviderErrors = make(map[ErrorKind]int64)
+	}
+	s.ProviderErrors[kind]++
+}
+
+func (s *ServerStats) IncrementCacheHits() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.CacheHits++
+}
+
+func (s *ServerStats) IncrementDiskCacheHits() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.DiskCacheHits++
+}
+
+func (s *ServerStats) IncrementCacheMisses() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.CacheMisses++
+}
+
+func (s *ServerStats) IncrementCoalescedSearches() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.CoalescedSearches++
+}
+
 func (s *ServerStats) GetStats() map[string]interface{} {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -99,6 +138,16 @@ func (s *ServerStats) GetStats() map[string]interface{} {
 
 	uptime := time.Since(s.StartTime)
 
+	var hitRatio float64
+	if lookups := s.CacheHits + s.DiskCacheHits + s.CacheMisses; lookups > 0 {
+		hitRatio = float64(s.CacheHits+s.DiskCacheHits) / float64(lookups)
+	}
+
+	providerErrors := make(map[ErrorKind]int64, len(s.ProviderErrors))
+	for kind, n := range s.ProviderErrors {
+		providerErrors[kind] = n
+	}
+
 	return map[string]interface{}{
 		"uptime_seconds":     uptime.Seconds(),
 		"uptime_human":       uptime.String(),
@@ -107,6 +156,14 @@ func (s *ServerStats) GetStats() map[string]interface{} {
 		"connection_count":   s.ConnectionCount,
 		"active_connections": s.ActiveConns,
 		"errors":             s.Errors,
+		"provider_errors":    providerErrors,
+		"cache": map[string]interface{}{
+			"hits":      s.CacheHits,
+			"disk_hits": s.DiskCacheHits,
+			"misses":    s.CacheMisses,
+			"hit_ratio": hitRatio,
+			"coalesced": s.CoalescedSearches,
+		},
 		"memory": map[string]interface{}{
 			"alloc_mb":       float64(m.Alloc) / 1024 / 1024,
 			"total_alloc_mb": float64(m.TotalAlloc) / 1024 / 1024,
@@ -125,6 +182,9 @@ type MCPMessage struct {
 	Params  interface{} `json:"params,omitempty"`
 	Result  interface{} `json:"result,omitempty"`
 	Error   *MCPError   `json:"error,omitempty"`
+
+	// hasID is set when the decoded message carried an "id" member, even null
+	hasID bool
 }
 
 type MCPError struct {
@@ -136,14 +196,27 @@ type MCPError struct {
 // MCP Server Info
 type ServerInfo struct {
 	Name    string `json:"name"`
+	Title   string `json:"title,omitempty"`
 	Version string `json:"version"`
 }
 
 // Tool definitions
 type Tool struct {
-	Name        string      `json:"name"`
-	Description string      `json:"description"`
-	InputSchema interface{} `json:"inputSchema"`
+	Name         string           `json:"name"`
+	Title        string           `json:"title,omitempty"`
+	Description  string           `json:"description"`
+	InputSchema  interface{}      `json:"inputSchema"`
+	OutputSchema interface{}      `json:"outputSchema,omitempty"`
+	Annotations  *ToolAnnotations `json:"annotations,omitempty"`
+}
+
+// ToolAnnotations are behavioural hints for clients (protocol 2025-03-26+)
+type ToolAnnotations struct {
+	Title           string `json:"title,omitempty"`
+	ReadOnlyHint    bool   `json:"readOnlyHint"`
+	DestructiveHint bool   `json:"destructiveHint"`
+	IdempotentHint  bool   `json:"idempotentHint"`
+	OpenWorldHint   bool   `json:"openWorldHint"`
 }
 
 type ToolSchema struct {
@@ -154,41 +227,157 @@ type ToolSchema struct {
 
 // Search result structures
 type SearchResult struct {
-	Title       string `json:"title"`
-	URL         string `json:"url"`
-	Description string `json:"description"`
-	Rank        int    `json:"rank"`
+	Title        string   `json:"title"`
+	URL          string   `json:"url"`
+	CanonicalURL string   `json:"canonical_url,omitempty"`
+	Description  string   `json:"description"`
+	Rank         int      `json:"rank"`
+	Providers    []string `json:"providers,omitempty"`
 }
 
 type SearchResponse struct {
-	Query   string         `json:"query"`
-	Results []SearchResult `json:"results"`
-	Count   int            `json:"count"`
+	Query      string         `json:"query"`
+	Results    []SearchResult `json:"results"`
+	Count      int            `json:"count"`
+	Providers  []string       `json:"providers,omitempty"`
+	DurationMS int64          `json:"duration_ms"`
+
+	// degraded marks an answer that providers which failed or were out of
+	// rotation might have changed; such answers are not cached
+	degraded bool
 }
 
 // WebSearchServer implements the MCP server
 type WebSearchServer struct {
-	stats  *ServerStats
-	logger *log.Logger
+	stats     *ServerStats
+	logger    *log.Logger
+	providers *ProviderRegistry
+	backoff   *providerBackoff
+	health    *healthTracker
+	retry     retryPolicy
+	// scrapers holds the HTML engines' scraping rules
+	scrapers *scraperSet
+	// limits holds the outbound rate limit of each limited provider
+	limits map[string]*tokenBucket
+	// maxQueueWait caps how long a search queues for a rate limit token
+	// before moving on to another provider
+	maxQueueWait time.Duration
+
+	cache *resultCache
+	// disk is the persistent cache, nil unless SEARCH_DISK_CACHE is set
+	disk      *diskCache
+	flights   *flightGroup
+	inFlight  *inFlightRequests
+	sessions  *sessionStore
+	legacySSE *sseConnections
+	// origins decides which browser origins may use the MCP endpoints
+	origins originPolicy
+
+	// maxConcurrency caps how many requests a connection handles at once
+	maxConcurrency int
 }
 
 func NewWebSearchServer() *WebSearchServer {
-	return &WebSearchServer{
+	s := &WebSearchServer{
 		stats: &ServerStats{
 			StartTime: time.Now(),
 		},
-		logger: log.New(os.Stderr, "[MCP] ", log.LstdFlags),
+		logger:         log.New(os.Stderr, "[MCP] ", log.LstdFlags),
+		backoff:        newProviderBackoff(envDuration("SEARCH_BLOCK_BACKOFF", time.Minute), 30*time.Minute),
+		health:         newHealthTracker(envInt("SEARCH_BREAKER_FAILURES", 5), envDuration("SEARCH_BREAKER_COOLDOWN", 30*time.Second)),
+		retry:          retryPolicyFromEnv(),
+		cache:          newResultCache(envDuration("SEARCH_CACHE_TTL", 10*time.Minute), envCount("SEARCH_CACHE_SIZE", 256)),
+		flights:        newFlightGroup(),
+		inFlight:       newInFlightRequests(),
+		sessions:       newSessionStore(envInt("MCP_MAX_SESSIONS", 1000)),
+		legacySSE:      newSSEConnections(),
+		origins:        originPolicyFromEnv(),
+		maxConcurrency: envInt("MCP_MAX_CONCURRENCY", 8),
+	}
+
+	s.scrapers = newScraperSet(os.Getenv("SEARCH_SCRAPERS_FILE"), s.logger)
+	registry, err := newDefaultRegistry(s.logger, s.scrapers, os.Getenv("SEARCH_PROVIDERS"))
+	if err != nil {
+		s.logger.Printf("%v; using default provider order", err)
+		registry, _ = newDefaultRegistry(s.logger, s.scrapers, "")
 	}
+	s.providers = registry
+
+	limits, err := newRateLimiters(registry, os.Getenv("SEARCH_RATE_LIMITS"))
+	if err != nil {
+		s.logger.Printf("Invalid SEARCH_RATE_LIMITS: %v; using default limits", err)
+		limits, _ = newRateLimiters(registry, "")
+	}
+	s.limits = limits
+	s.maxQueueWait = envDuration("SEARCH_RATE_LIMIT_WAIT", 2*time.Second)
+	if os.Getenv("SEARCH_REPLAY_DIR") != "" {
+		// Fixtures cost nothing to serve, and throttling would only slow
+		// down replayed test runs
+		s.limits = nil
+	}
+
+	if os.Getenv("SEARCH_DISK_CACHE") == "1" {
+		if s.disk, err = diskCacheFromEnv(); err != nil {
+			s.logger.Printf("Disk cache disabled: %v", err)
+		}
+	}
+	return s
 }
 
-func (s *WebSearchServer) handleMessage(msg MCPMessage) *MCPMessage {
+// handleMessage dispatches a single message. ctx is cancelled when the client
+// goes away or the server shuts down, which aborts in-flight searches.
+// Notifications and client responses never produce a reply, so the result is
+// nil for them.
+func (s *WebSearchServer) handleMessage(ctx context.Context, msg MCPMessage) *MCPMessage {
+	if msg.isResponse() {
+		// The server never sends requests, so there is nothing to match
+		return nil
+	}
+	if msg.isNotification() {
+		s.handleNotification(ctx, msg)
+		return nil
+	}
+	if msg.JSONRPC != "2.0" || msg.Method == "" || !validID(msg.ID) {
+		return &MCPMessage{
+			JSONRPC: "2.0",
+			ID:      msg.ID,
+			Error: &MCPError{
+				Code:    -32600,
+				Message: "Invalid Request",
+			},
+		}
+	}
+
+	// Tools may only be called once the session has been initialized
+	sess := sessionFromContext(ctx)
+	if sess != nil && !sess.Initialized() && msg.Method == "tools/call" {
+		return &MCPMessage{
+			JSONRPC: "2.0",
+			ID:      msg.ID,
+			Error: &MCPError{
+				Code:    -32002,
+				Message: "Server not initialized",
+			},
+		}
+	}
+
 	switch msg.Method {
 	case "initialize":
-		return s.handleInitialize(msg)
+		response, version := s.handleInitialize(msg)
+		if sess != nil {
+			sess.markInitialized(version)
+		}
+		return response
 	case "tools/list":
-		return s.handleToolsList(msg)
+		return s.handleToolsList(ctx, msg)
 	case "tools/call":
-		return s.handleToolsCall(msg)
+		callCtx, finish := s.inFlightFor(ctx).track(ctx, msg.ID)
+		response := s.handleToolsCall(callCtx, msg)
+		if finish() {
+			// The client cancelled the request; the spec says not to respond
+			return nil
+		}
+		return response
 	case "ping":
 		return s.handlePing(msg)
 	case "stats/get":
@@ -205,25 +394,51 @@ func (s *WebSearchServer) handleMessage(msg MCPMessage) *MCPMessage {
 	}
 }
 
-func (s *WebSearchServer) handleInitialize(msg MCPMessage) *MCPMessage {
-	versionInfo := getVersionInfo()
+// handleNotification handles client notifications. Unknown ones are ignored
+// as JSON-RPC requires.
+func (s *WebSearchServer) handleNotification(ctx context.Context, msg MCPMessage) {
+	switch msg.Method {
+	case "notifications/initialized":
+		s.logger.Println("Client initialized")
+	case "notifications/cancelled":
+		s.handleCancelled(ctx, msg)
+	}
+}
+
+// handleInitialize answers the handshake and returns the protocol revision it
+// agreed on
+func (s *WebSearchServer) handleInitialize(msg MCPMessage) (*MCPMessage, string) {
+	var requested string
+	if params, ok := msg.Params.(map[string]interface{}); ok {
+		requested, _ = params["protocolVersion"].(string)
+	}
+	version := negotiateProtocolVersion(requested)
+	if version != requested {
+		s.logger.Printf("Client requested protocol version %q, offering %s", requested, version)
+	}
+
+	serverInfo := ServerInfo{
+		Name:    "websearch-mcp",
+		Version: getVersionInfo().Version,
+	}
+	if version >= versionToolTitles {
+		serverInfo.Title = "WebSearch MCP Server"
+	}
+
 	return &MCPMessage{
 		JSONRPC: "2.0",
 		ID:      msg.ID,
 		Result: map[string]interface{}{
-			"protocolVersion": "2024-11-05",
+			"protocolVersion": version,
 			"capabilities": map[string]interface{}{
 				"tools": map[string]interface{}{},
 			},
-			"serverInfo": ServerInfo{
-				Name:    "websearch-mcp",
-				Version: versionInfo.Version,
-			},
+			"serverInfo": serverInfo,
 		},
-	}
+	}, version
 }
 
-func (s *WebSearchServer) handleToolsList(msg MCPMessage) *MCPMessage {
+func (s *WebSearchServer) handleToolsList(ctx context.Context, msg MCPMessage) *MCPMessage {
 	tools := []Tool{
 		{
 			Name:        "web_search",
@@ -238,9 +453,9 @@ func (s *WebSearchServer) handleToolsList(msg MCPMessage) *MCPMessage {
 					"max_results": map[string]interface{}{
 						"type":        "integer",
 						"description": "Maximum number of results to return (default: 10)",
-						"default":     10,
+						"default":     defaultMaxResults,
 						"minimum":     1,
-						"maximum":     20,
+						"maximum":     maxMaxResults,
 					},
 				},
 				Required: []string{"query"},
@@ -248,6 +463,23 @@ func (s *WebSearchServer) handleToolsList(msg MCPMessage) *MCPMessage {
 		},
 	}
 
+	// Fields newer clients understand; older ones may reject unknown keys
+	for i := range tools {
+		if supportsFeature(ctx, versionToolTitles) {
+			tools[i].Title = "Web Search"
+		}
+		if supportsFeature(ctx, versionStructuredOutput) {
+			tools[i].OutputSchema = searchResponseSchema()
+		}
+		if supportsFeature(ctx, versionToolAnnotations) {
+			tools[i].Annotations = &ToolAnnotations{
+				Title:         "Web Search",
+				ReadOnlyHint:  true,
+				OpenWorldHint: true,
+			}
+		}
+	}
+
 	return &MCPMessage{
 		JSONRPC: "2.0",
 		ID:      msg.ID,
@@ -257,7 +489,7 @@ func (s *WebSearchServer) handleToolsList(msg MCPMessage) *MCPMessage {
 	}
 }
 
-func (s *WebSearchServer) handleToolsCall(msg MCPMessage) *MCPMessage {
+func (s *WebSearchServer) handleToolsCall(ctx context.Context, msg MCPMessage) *MCPMessage {
 	params, ok := msg.Params.(map[string]interface{})
 	if !ok {
 		return &MCPMessage{
@@ -296,7 +528,7 @@ func (s *WebSearchServer) handleToolsCall(msg MCPMessage) *MCPMessage {
 
 	switch name {
 	case "web_search":
-		return s.handleWebSearch(msg, arguments)
+		return s.handleWebSearch(ctx, msg, arguments)
 	default:
 		return &MCPMessage{
 			JSONRPC: "2.0",
@@ -309,7 +541,7 @@ func (s *WebSearchServer) handleToolsCall(msg MCPMessage) *MCPMessage {
 	}
 }
 
-func (s *WebSearchServer) handleWebSearch(msg MCPMessage, args map[string]interface{}) *MCPMessage {
+func (s *WebSearchServer) handleWebSearch(ctx context.Context, msg MCPMessage, args map[string]interface{}) *MCPMessage {
 	query, ok := args["query"].(string)
 	if !ok || query == "" {
 		return &MCPMessage{
@@ -322,301 +554,156 @@ func (s *WebSearchServer) handleWebSearch(msg MCPMessage, args map[string]interf
 		}
 	}
 
-	maxResults := 10
+	maxResults := defaultMaxResults
 	if mr, ok := args["max_results"].(float64); ok {
+		// Clamp to the range advertised in the input schema
 		maxResults = int(mr)
+		if maxResults < 1 {
+			maxResults = 1
+		} else if maxResults > maxMaxResults {
+			maxResults = maxMaxResults
+		}
 	}
 
 	s.stats.IncrementSearches()
-	results, err := s.performWebSearch(query, maxResults)
+	start := time.Now()
+	results, err := s.performWebSearch(ctx, query, maxResults)
 	if err != nil {
+		// A failed search is a tool result the model can react to, not a
+		// protocol error
 		s.stats.IncrementErrors()
-		return &MCPMessage{
-			JSONRPC: "2.0",
-			ID:      msg.ID,
-			Error: &MCPError{
-				Code:    -32603,
-				Message: fmt.Sprintf("Search failed: %v", err),
-			},
-		}
+		return toolErrorResult(msg, s.describeSearchError(err), err)
+	}
+
+	results.DurationMS = time.Since(start).Milliseconds()
+	if results.Results == nil {
+		// The output schema promises an array
+		results.Results = []SearchResult{}
 	}
 
+	result := map[string]interface{}{
+		"content": []map[string]interface{}{
+			{
+				"type": "text",
+				"text": s.formatSearchResults(results),
+			},
+		},
+	}
+	if supportsFeature(ctx, versionStructuredOutput) {
+		result["structuredContent"] = results
+	}
 	return &MCPMessage{
 		JSONRPC: "2.0",
 		ID:      msg.ID,
-		Result: map[string]interface{}{
-			"content": []map[string]interface{}{
-				{
-					"type": "text",
-					"text": s.formatSearchResults(results),
-				},
-			},
-		},
+		Result:  result,
 	}
 }
 
-func (s *WebSearchServer) performWebSearch(query string, maxResults int) (*SearchResponse, error) {
-	// Choose provider via env (default: auto -> Mojeek -> DuckDuckGo -> Wikipedia)
-	provider := strings.ToLower(strings.TrimSpace(os.Getenv("SEARCH_PROVIDER")))
-	switch provider {
-	case "duckduckgo", "ddg":
-		return s.performDuckDuckGoSearch(query, maxResults)
-	case "mojeek":
-		return s.performMojeekSearch(query, maxResults)
-	case "wikipedia", "wiki":
-		return s.performWikipediaSearch(query, maxResults)
-	case "auto", "":
-		// Try Mojeek, then DuckDuckGo, then Wikipedia
-		if res, err := s.performMojeekSearch(query, maxResults); err == nil && len(res.Results) > 0 {
-			return res, nil
-		}
-		if res, err := s.performDuckDuckGoSearch(query, maxResults); err == nil && len(res.Results) > 0 {
-			return res, nil
-		}
-		return s.performWikipediaSearch(query, maxResults)
-	default:
-		// Unknown provider -> auto fallback
-		if res, err := s.performMojeekSearch(query, maxResults); err == nil && len(res.Results) > 0 {
-			return res, nil
-		}
-		if res, err := s.performDuckDuckGoSearch(query, maxResults); err == nil && len(res.Results) > 0 {
-			return res, nil
-		}
-		return s.performWikipediaSearch(query, maxResults)
-	}
+// remedies suggests what the model can do about each kind of provider failure
+var remedies = map[ErrorKind]string{
+	ErrTimeout:     "The provider did not answer in time. Retry the search, possibly with a shorter query.",
+	ErrRateLimited: "The provider is limiting requests. Wait a few minutes before searching again.",
+	ErrBlocked:     "The provider is blocking automated requests. Wait before retrying or use another provider.",
+	ErrUpstream:    "The provider is having problems of its own. Retry later.",
+	ErrParse:       "The provider's page layout may have changed; it will keep failing until the server is updated.",
+	ErrNetwork:     "The provider could not be reached. Check the server's network connection.",
+	ErrOther:       "Retry with a rephrased query.",
+	ErrUnavailable: "The provider has been failing repeatedly and is skipped while it recovers. Retry in a little while.",
+	ErrThrottled:   "Searches are being sent faster than the server's rate limit for the provider allows. Wait a few seconds before searching again.",
 }
 
-func (s *WebSearchServer) performDuckDuckGoSearch(query string, maxResults int) (*SearchResponse, error) {
-	searchURL := fmt.Sprintf("https://html.duckduckgo.com/html/?q=%s", url.QueryEscape(query))
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	req, err := http.NewRequest("GET", searchURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-	// Headers to mimic a browser
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
-	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
-	// Do not set Accept-Encoding manually; let Go auto-handle gzip to avoid manual decompression
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to perform search: %w", err)
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("search request failed with status: %d", resp.StatusCode)
-	}
-
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+// describeSearchError explains a failed search to the model: which providers
+// failed, why, and what it can do about it
+func (s *WebSearchServer) describeSearchError(err error) string {
+	failures := providerErrors(err)
+	if len(failures) == 0 {
+		return fmt.Sprintf("Web search failed: %v", err)
 	}
 
-	var results []SearchResult
-	rank := 1
-	// DDG HTML results
-	doc.Find(".result").Each(func(i int, sel *goquery.Selection) {
-		if rank > maxResults {
-			return
-		}
-		// Skip ads and non-organic blocks
-		cls := sel.AttrOr("class", "")
-		if strings.Contains(cls, "result--ad") || strings.Contains(cls, "result--more") {
-			return
-		}
-		// Try common selectors for title link
-		titleLink := sel.Find("a.result__a")
-		if titleLink.Length() == 0 {
-			titleLink = sel.Find(".result__title a")
-		}
-		title := strings.TrimSpace(titleLink.Text())
-		href, exists := titleLink.Attr("href")
-		if !exists || title == "" {
-			return
-		}
-
-		// Resolve DDG redirect links like /l/?uddg=...
-		finalURL := href
-		if strings.HasPrefix(href, "/l/?") || strings.Contains(href, "duckduckgo.com/l/?") {
-			// Build absolute URL to parse query
-			base, _ := url.Parse("https://duckduckgo.com")
-			rel, err := url.Parse(href)
-			if err == nil {
-				abs := base.ResolveReference(rel)
-				uddg := abs.Query().Get("uddg")
-				if uddg != "" {
-					if decoded, err := url.QueryUnescape(uddg); err == nil {
-						finalURL = decoded
-					} else {
-						finalURL = uddg
-					}
-				}
-			}
-		}
-		// Skip if not an http(s) URL or if it's an internal DDG ad/tracking link
-		if !(strings.HasPrefix(finalURL, "http://") || strings.HasPrefix(finalURL, "https://")) {
-			return
-		}
-		if strings.Contains(finalURL, "duckduckgo.com/y.js") || strings.Contains(finalURL, "ad_provider=") {
-			return
-		}
-
-		desc := strings.TrimSpace(sel.Find(".result__snippet").Text())
-		if desc == "" {
-			desc = strings.TrimSpace(sel.Find(".result__snippet.js-result-snippet").Text())
-		}
-
-		results = append(results, SearchResult{
-			Title:       title,
-			URL:         finalURL,
-			Description: desc,
-			Rank:        rank,
-		})
-		rank++
-	})
-
-	return &SearchResponse{Query: query, Results: results, Count: len(results)}, nil
-}
-
-func (s *WebSearchServer) performMojeekSearch(query string, maxResults int) (*SearchResponse, error) {
-	searchURL := fmt.Sprintf("https://www.mojeek.com/search?q=%s", url.QueryEscape(query))
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	req, err := http.NewRequest("GET", searchURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+	var builder strings.Builder
+	builder.WriteString("Web search failed:\n")
+	seen := make(map[ErrorKind]bool)
+	for _, pe := range failures {
+		builder.WriteString(fmt.Sprintf("- %v\n", pe))
+		seen[pe.Kind] = true
 	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
-	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to perform search: %w", err)
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("search request failed with status: %d", resp.StatusCode)
-	}
-
-	var reader io.Reader = resp.Body
-	if os.Getenv("SEARCH_DEBUG") == "1" {
-		// tee a small preview to stderr for debugging selectors
-		buf := new(strings.Builder)
-		limited := io.TeeReader(io.LimitReader(reader, 4096), buf)
-		_, _ = io.ReadAll(limited)
-		s.logger.Printf("[DEBUG] Mojeek HTML preview: %q", buf.String())
-		// Reset reader to full body (we consumed 4096). Fetch again entirely for parsing.
-		// Note: For simplicity, re-issue the request in debug mode to get a fresh body.
-		resp.Body.Close()
-		resp, err = client.Get(searchURL)
-		if err != nil {
-			return nil, fmt.Errorf("failed to refetch for debug: %w", err)
+	for _, kind := range []ErrorKind{ErrTimeout, ErrRateLimited, ErrBlocked, ErrUpstream, ErrParse, ErrNetwork, ErrUnavailable, ErrThrottled, ErrOther} {
+		if seen[kind] {
+			builder.WriteString(remedies[kind] + "\n")
 		}
-		defer resp.Body.Close()
-		reader = resp.Body
-	}
-
-	doc, err := goquery.NewDocumentFromReader(reader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse HTML: %w", err)
 	}
-
-	var results []SearchResult
-	rank := 1
-	// Mojeek uses a simple results list; try multiple robust selectors
-	doc.Find("#results .result, li.result, div.result").Each(func(i int, sel *goquery.Selection) {
-		if rank > maxResults {
-			return
-		}
-		a := sel.Find("a").First()
-		title := strings.TrimSpace(a.Text())
-		href, exists := a.Attr("href")
-		if !exists || title == "" {
-			return
-		}
-		// Filter internal navigation links
-		if strings.HasPrefix(href, "/search") || strings.Contains(href, "www.mojeek.com/search") {
-			return
-		}
-		// Resolve relative links just in case
-		finalURL := href
-		if strings.HasPrefix(href, "/") {
-			base, _ := url.Parse("https://www.mojeek.com")
-			rel, err := url.Parse(href)
-			if err == nil {
-				finalURL = base.ResolveReference(rel).String()
-			}
-		}
-
-		desc := strings.TrimSpace(sel.Find("p.s, .s, p").First().Text())
-
-		results = append(results, SearchResult{
-			Title:       title,
-			URL:         finalURL,
-			Description: desc,
-			Rank:        rank,
-		})
-		rank++
-	})
-
-	return &SearchResponse{Query: query, Results: results, Count: len(results)}, nil
+	builder.WriteString(fmt.Sprintf("If the problem persists, the server can be started with SEARCH_PROVIDER set to one of: %s.", strings.Join(s.providers.Names(), ", ")))
+	return builder.String()
 }
 
-func (s *WebSearchServer) performWikipediaSearch(query string, maxResults int) (*SearchResponse, error) {
-	// Use MediaWiki API (no API key) for a reliable fallback
-	apiURL := fmt.Sprintf("https://en.wikipedia.org/w/api.php?action=query&list=search&format=json&utf8=1&srsearch=%s&srlimit=%d", url.QueryEscape(query), maxResults)
-
-	client := &http.Client{Timeout: 20 * time.Second}
-	req, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+// toolErrorResult reports a tool execution failure as a CallToolResult with
+// isError set. Provider failures are attached under _meta so clients can act
+// on their kinds without parsing the text. This is the only place they
+// surface: search failures never become JSON-RPC errors, so MCPError.Data
+// never carries them.
+func toolErrorResult(msg MCPMessage, text string, err error) *MCPMessage {
+	result := map[string]interface{}{
+		"content": []map[string]interface{}{
+			{
+				"type": "text",
+				"text": text,
+			},
+		},
+		"isError": true,
 	}
-	req.Header.Set("User-Agent", "websearch-mcp/"+version+" (+https://example.com) Go-http-client")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to perform search: %w", err)
+	if failures := providerErrors(err); len(failures) > 0 {
+		result["_meta"] = map[string]interface{}{"errors": failures}
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("search request failed with status: %d", resp.StatusCode)
+	return &MCPMessage{
+		JSONRPC: "2.0",
+		ID:      msg.ID,
+		Result:  result,
 	}
+}
 
-	var data struct {
-		Query struct {
-			Search []struct {
-				Title   string `json:"title"`
-				PageID  int    `json:"pageid"`
-				Snippet string `json:"snippet"`
-			} `json:"search"`
-		} `json:"query"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return nil, fmt.Errorf("failed to decode JSON: %w", err)
+// searchResponseSchema describes SearchResponse as returned in
+// structuredContent by web_search
+func searchResponseSchema() ToolSchema {
+	result := ToolSchema{
+		Type: "object",
+		Properties: map[string]interface{}{
+			"title": map[string]interface{}{"type": "string"},
+			"url": map[string]interface{}{
+				"type":        "string",
+				"description": "Result URL as returned by the provider, with tracking parameters removed",
+			},
+			"canonical_url": map[string]interface{}{
+				"type":        "string",
+				"description": "Normalized URL used to deduplicate results across providers",
+			},
+			"description": map[string]interface{}{"type": "string"},
+			"rank":        map[string]interface{}{"type": "integer", "minimum": 1},
+			"providers": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Providers that returned this result",
+			},
+		},
+		Required: []string{"title", "url", "rank"},
 	}
-
-	results := make([]SearchResult, 0, len(data.Query.Search))
-	for i, item := range data.Query.Search {
-		if i >= maxResults {
-			break
-		}
-		url := fmt.Sprintf("https://en.wikipedia.org/?curid=%d", item.PageID)
-		// Strip HTML from snippet
-		desc := strings.ReplaceAll(item.Snippet, "<span class=\"searchmatch\">", "")
-		desc = strings.ReplaceAll(desc, "</span>", "")
-		desc = strings.ReplaceAll(desc, "<span class=\"searchalttitle\">", "")
-		desc = strings.ReplaceAll(desc, "</span>", "")
-		results = append(results, SearchResult{
-			Title:       item.Title,
-			URL:         url,
-			Description: desc,
-			Rank:        i + 1,
-		})
+	return ToolSchema{
+		Type: "object",
+		Properties: map[string]interface{}{
+			"query":   map[string]interface{}{"type": "string"},
+			"results": map[string]interface{}{"type": "array", "items": result},
+			"count":   map[string]interface{}{"type": "integer", "minimum": 0},
+			"providers": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Providers whose responses were used",
+			},
+			"duration_ms": map[string]interface{}{
+				"type":        "integer",
+				"description": "Time spent searching, in milliseconds",
+			},
+		},
+		Required: []string{"query", "results", "count"},
 	}
-	return &SearchResponse{Query: query, Results: results, Count: len(results)}, nil
 }
 
 func (s *WebSearchServer) formatSearchResults(response *SearchResponse) string {
@@ -631,6 +718,9 @@ func (s *WebSearchServer) formatSearchResults(response *SearchResponse) string {
 	for _, result := range response.Results {
 		builder.WriteString(fmt.Sprintf("%d. %s\n", result.Rank, result.Title))
 		builder.WriteString(fmt.Sprintf("   URL: %s\n", result.URL))
+		if len(result.Providers) > 1 {
+			builder.WriteString(fmt.Sprintf("   Sources: %s\n", strings.Join(result.Providers, ", ")))
+		}
 		if result.Description != "" {
 			builder.WriteString(fmt.Sprintf("   Description: %s\n", result.Description))
 		}
@@ -640,6 +730,23 @@ func (s *WebSearchServer) formatSearchResults(response *SearchResponse) string {
 	return builder.String()
 }
 
+// handleCancelled aborts an in-flight request named by a cancellation
+// notification. Unknown or already finished requests are ignored.
+func (s *WebSearchServer) handleCancelled(ctx context.Context, msg MCPMessage) {
+	params, ok := msg.Params.(map[string]interface{})
+	if !ok {
+		return
+	}
+	requestID, ok := params["requestId"]
+	if !ok {
+		return
+	}
+	reason, _ := params["reason"].(string)
+	if s.inFlightFor(ctx).cancel(requestID) {
+		s.logger.Printf("Cancelled request %v: %s", requestID, reason)
+	}
+}
+
 func (s *WebSearchServer) handlePing(msg MCPMessage) *MCPMessage {
 	return &MCPMessage{
 		JSONRPC: "2.0",
@@ -652,25 +759,53 @@ func (s *WebSearchServer) handleGetStats(msg MCPMessage) *MCPMessage {
 	return &MCPMessage{
 		JSONRPC: "2.0",
 		ID:      msg.ID,
-		Result:  s.stats.GetStats(),
+		Result:  s.statsSnapshot(),
 	}
 }
 
-// Run stdio mode - communicate via standard input/output
-func (s *WebSearchServer) runStdio() error {
+// statsSnapshot adds provider state to the server counters
+func (s *WebSearchServer) statsSnapshot() map[string]interface{} {
+	stats := s.stats.GetStats()
+	stats["benched_providers"] = s.backoff.snapshot()
+	stats["providers"] = s.health.snapshot(s.providers.Names())
+	stats["cache"].(map[string]interface{})["entries"] = s.cache.len()
+	return stats
+}
+
+// Run stdio mode - communicate via standard input/output.
+// Returns when stdin is closed or ctx is cancelled.
+func (s *WebSearchServer) runStdio(ctx context.Context) error {
 	s.logger.Println("Starting MCP server in stdio mode")
 
-	reader := bufio.NewReader(os.Stdin)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ctx = withSession(ctx, NewSession())
 	encoder := json.NewEncoder(os.Stdout)
+	lines, readErrs := readLines(os.Stdin)
+
+	// Requests run concurrently so a slow search does not hold up pings or
+	// other searches; responses go out by ID in completion order
+	d := s.newDispatcher(s.maxConcurrency, func(response interface{}) error {
+		return encoder.Encode(response)
+	})
+	defer d.wait()
 
 	for {
-		line, err := reader.ReadString('\n')
-		if err != nil {
+		var line string
+		select {
+		case <-ctx.Done():
+			s.logger.Println("Shutting down stdio server...")
+			return nil
+		case err := <-d.errs():
+			return fmt.Errorf("error writing to stdout: %w", err)
+		case err := <-readErrs:
 			if err == io.EOF {
 				s.logger.Println("Received EOF, shutting down")
 				return nil
 			}
 			return fmt.Errorf("error reading from stdin: %w", err)
+		case line = <-lines:
 		}
 
 		line = strings.TrimSpace(line)
@@ -678,80 +813,63 @@ func (s *WebSearchServer) runStdio() error {
 			continue
 		}
 
-		var msg MCPMessage
-		if err := json.Unmarshal([]byte(line), &msg); err != nil {
-			s.logger.Printf("Error unmarshaling message: %v", err)
-			s.stats.IncrementErrors()
-			continue
-		}
-
-		s.stats.IncrementRequests()
-		response := s.handleMessage(msg)
+		d.dispatchPayload(ctx, []byte(line))
+	}
+}
 
-		if response != nil {
-			if err := encoder.Encode(response); err != nil {
-				s.logger.Printf("Error encoding response: %v", err)
-				s.stats.IncrementErrors()
-				return fmt.Errorf("error writing to stdout: %w", err)
+// readLines reads r line by line in the background so callers can select on
+// cancellation while waiting for input. The error channel receives io.EOF
+// when r is exhausted.
+func readLines(r io.Reader) (<-chan string, <-chan error) {
+	lines := make(chan string)
+	errs := make(chan error, 1)
+	go func() {
+		reader := bufio.NewReader(r)
+		for {
+			line, err := reader.ReadString('\n')
+			if len(line) > 0 {
+				lines <- line
+			}
+			if err != nil {
+				errs <- err
+				return
 			}
 		}
-	}
+	}()
+	return lines, errs
 }
 
 // Run HTTP mode (for backward compatibility and testing)
-func (s *WebSearchServer) runHTTP(port string) error {
+// Request contexts derive from ctx, so cancelling it aborts in-flight
+// searches as well as stopping the listener.
+func (s *WebSearchServer) runHTTP(ctx context.Context, port string) error {
 	s.logger.Printf("Starting MCP server in HTTP mode on port %s", port)
 
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		versionInfo := getVersionInfo()
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"status":    "healthy",
-			"service":   "websearch-mcp",
-			"version":   versionInfo.Version,
-			"timestamp": time.Now().UTC().Format(time.RFC3339),
-			"build_info": map[string]interface{}{
-				"version":    versionInfo.Version,
-				"build_time": versionInfo.BuildTime,
-				"git_commit": versionInfo.GitCommit,
-				"go_version": versionInfo.GoVersion,
-			},
-		})
-	})
-
-	http.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(s.stats.GetStats())
-	})
-
-	http.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(getVersionInfo())
-	})
-
 	srv := &http.Server{
 		Addr:         ":" + port,
+		Handler:      s.httpHandler(),
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
+		BaseContext:  func(net.Listener) context.Context { return ctx },
 	}
 
 	// Graceful shutdown
 	go func() {
-		c := make(chan os.Signal, 1)
-		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-		<-c
+		<-ctx.Done()
 
 		s.logger.Println("Shutting down server...")
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		if err := srv.Shutdown(ctx); err != nil {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
 			s.logger.Printf("Server shutdown error: %v", err)
 		}
 	}()
+	go s.expireSessions(ctx, envDuration("MCP_SESSION_TTL", 30*time.Minute))
 
+	s.logger.Printf("MCP endpoint: http://localhost:%s/mcp", port)
+	s.logger.Printf("Legacy SSE endpoint: http://localhost:%s/sse", port)
 	s.logger.Printf("Health endpoint: http://localhost:%s/health", port)
 	s.logger.Printf("Stats endpoint: http://localhost:%s/stats", port)
 	s.logger.Printf("Version endpoint: http://localhost:%s/version", port)
@@ -764,8 +882,64 @@ func (s *WebSearchServer) runHTTP(port string) error {
 	return nil
 }
 
+// httpHandler builds the routes served in HTTP mode
+func (s *WebSearchServer) httpHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/mcp", s.checkOrigin(s.handleStreamableHTTP))
+	mux.HandleFunc("/sse", s.checkOrigin(s.handleLegacySSE))
+	mux.HandleFunc("/messages", s.checkOrigin(s.handleLegacyMessage))
+	mux.HandleFunc("/", s.checkOrigin(s.handleWebSocket))
+
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		versionInfo := getVersionInfo()
+
+		// Provider outages degrade search but do not make the server itself
+		// unhealthy, so the status code stays 200
+		providers := s.health.snapshot(s.providers.Names())
+		status := "healthy"
+		for _, p := range s.providers.Enabled() {
+			if providers[p.Name()].State != breakerClosed {
+				status = "degraded"
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":    status,
+			"providers": providers,
+			"service":   "websearch-mcp",
+			"version":   versionInfo.Version,
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"build_info": map[string]interface{}{
+				"version":    versionInfo.Version,
+				"build_time": versionInfo.BuildTime,
+				"git_commit": versionInfo.GitCommit,
+				"go_version": versionInfo.GoVersion,
+			},
+		})
+	})
+
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.statsSnapshot())
+	})
+
+	mux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(getVersionInfo())
+	})
+
+	return mux
+}
+
 func main() {
-	server := NewWebSearchServer()
+	if len(os.Args) > 1 && os.Args[1] == "cache" {
+		if err := runCacheCommand(os.Args[2:]); err != nil {
+			log.Fatalf("Cache error: %v", err)
+		}
+		return
+	}
 
 	// Check if we should run in HTTP mode (for backward compatibility)
 	// If PORT environment variable is set or --http flag is provided, run in HTTP mode
@@ -780,6 +954,16 @@ func main() {
 			if i+1 < len(os.Args) && !strings.HasPrefix(os.Args[i+1], "--") {
 				port = os.Args[i+1]
 			}
+		} else if arg == "--record" || arg == "--replay" {
+			if i+1 >= len(os.Args) || strings.HasPrefix(os.Args[i+1], "--") {
+				log.Fatalf("%s requires a fixture directory", arg)
+			}
+			// Providers pick their transport up from the environment
+			if arg == "--record" {
+				os.Setenv("SEARCH_RECORD_DIR", os.Args[i+1])
+			} else {
+				os.Setenv("SEARCH_REPLAY_DIR", os.Args[i+1])
+			}
 		} else if arg == "--stdio" {
 			mode = "stdio"
 		} else if arg == "--version" || arg == "-v" {
@@ -795,35 +979,81 @@ func main() {
 			fmt.Println()
 			fmt.Println("Usage:")
 			fmt.Println("  websearch-mcp [options]")
+			fmt.Println("  websearch-mcp cache [info|purge]  Inspect or clear the disk cache")
 			fmt.Println()
 			fmt.Println("Options:")
 			fmt.Println("  --stdio           Run in stdio mode (default)")
 			fmt.Println("  --http [port]     Run in HTTP mode on specified port (default: 8080)")
+			fmt.Println("  --record DIR      Save every provider HTTP exchange as a fixture in DIR")
+			fmt.Println("  --replay DIR      Serve provider requests from fixtures in DIR, without network access")
 			fmt.Println("  --version, -v     Show version information")
 			fmt.Println("  --help, -h        Show this help message")
 			fmt.Println()
 			fmt.Println("Environment Variables:")
 			fmt.Println("  MCP_MODE          Set to 'http' or 'stdio' (default: stdio)")
 			fmt.Println("  PORT              Port for HTTP mode (default: 8080)")
-			fmt.Println("  SEARCH_PROVIDER   Search provider: 'mojeek', 'duckduckgo', 'wikipedia' or 'auto' (default: auto)")
+			fmt.Println("  MCP_ALLOWED_ORIGINS  Extra browser origins allowed besides localhost, or '*' for any (default: none)")
+			fmt.Println("  MCP_MAX_CONCURRENCY  Requests handled concurrently per connection (default: 8)")
+			fmt.Println("  MCP_SESSION_TTL   Idle time before an HTTP session expires (default: 30m)")
+			fmt.Println("  MCP_MAX_SESSIONS  Live HTTP sessions kept at once; the longest idle one is closed to make room (default: 1000)")
+			fmt.Println("  SEARCH_PROVIDER   Search provider: 'mojeek', 'duckduckgo', 'wikipedia', 'auto' or 'merge' (default: auto)")
+			fmt.Println("  SEARCH_MERGE_TIMEOUT  Shared deadline for all providers in merge mode (default: 15s)")
+			fmt.Println("  SEARCH_PROVIDERS  Comma-separated providers enabled for auto mode, in order (default: mojeek,duckduckgo,wikipedia)")
+			fmt.Println("  SEARCH_BLOCK_BACKOFF  First pause for a provider that serves a CAPTCHA/block page, doubling up to 30m (default: 1m)")
+			fmt.Println("  SEARCH_BREAKER_FAILURES  Consecutive failures before a provider is skipped (default: 5)")
+			fmt.Println("  SEARCH_BREAKER_COOLDOWN  How long a failing provider is skipped before it is probed again (default: 30s)")
+			fmt.Println("  SEARCH_RETRIES    Retries for network errors, 502/503/504 and 429 responses (default: 1)")
+			fmt.Println("  SEARCH_RETRY_BASE First retry backoff, doubled per attempt with jitter (default: 250ms)")
+			fmt.Println("  SEARCH_RETRY_MAX  Longest backoff or Retry-After the server will wait (default: 5s)")
+			fmt.Println("  SEARCH_HEDGE_DELAY  Start the next provider when one has not answered within this time in auto mode (default: off)")
+			fmt.Println("  SEARCH_RATE_LIMITS  Per-provider request limits, e.g. 'duckduckgo=1/s:3,wikipedia=off' (default: 30/m:5 for scraped providers)")
+			fmt.Println("  SEARCH_RATE_LIMIT_WAIT  Longest a search queues for a rate limit before trying another provider (default: 2s)")
+			fmt.Println("  SEARCH_CACHE_TTL  How long search results are cached (default: 10m)")
+			fmt.Println("  SEARCH_CACHE_SIZE Maximum number of cached searches, 0 to disable caching (default: 256)")
+			fmt.Println("  SEARCH_DISK_CACHE Set to '1' to keep search results on disk across restarts (default: disabled)")
+			fmt.Println("  SEARCH_DISK_CACHE_DIR  Directory for the disk cache (default: websearch-mcp in the user cache dir)")
+			fmt.Println("  SEARCH_DISK_CACHE_TTL  How long results stay in the disk cache (default: 24h)")
+			fmt.Println("  SEARCH_DISK_CACHE_MAX_MB  Size limit of the disk cache file; oldest entries go first (default: 16)")
+			fmt.Println("  SEARCH_SCRAPERS_FILE  JSON file overriding or adding HTML scraping rules, reloaded when it changes (default: built-in rules)")
+			fmt.Println("  SEARCH_<PROVIDER>_URL  Endpoint for a provider, e.g. SEARCH_WIKIPEDIA_URL for a self-hosted MediaWiki api.php")
+			fmt.Println("  SEARCH_<PROVIDER>_HEADERS  Extra request headers for a provider, as 'Name: value|Name: value'")
+			fmt.Println("  SEARCH_<PROVIDER>_TIMEOUT  HTTP timeout for a provider (default: 30s, 20s for wikipedia)")
+			fmt.Println("  SEARCH_RECORD_DIR Same as --record")
+			fmt.Println("  SEARCH_REPLAY_DIR Same as --replay")
 			fmt.Println("  SEARCH_DEBUG      Set to '1' to enable debug output for HTML parsing (default: disabled)")
 			return
 		}
 	}
 
+	server := NewWebSearchServer()
+	if dir := os.Getenv("SEARCH_REPLAY_DIR"); dir != "" {
+		server.logger.Printf("Replaying provider traffic from %s", dir)
+	} else if dir := os.Getenv("SEARCH_RECORD_DIR"); dir != "" {
+		server.logger.Printf("Recording provider traffic to %s", dir)
+	}
+
 	// Default to stdio mode if not specified
 	if mode == "" {
 		mode = "stdio"
 	}
 
+	// Cancelled on SIGINT/SIGTERM so in-flight searches stop immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	var err error
 	if mode == "http" {
 		if port == "" {
 			port = "8080"
 		}
-		err = server.runHTTP(port)
+		err = server.runHTTP(ctx, port)
 	} else {
-		err = server.runStdio()
+		err = server.runStdio(ctx)
+	}
+	if server.disk != nil {
+		// Let a pending cache write finish instead of leaving its lock file
+		// behind
+		server.disk.flush()
 	}
 
 	if err != nil {
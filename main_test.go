@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -26,7 +29,7 @@ func TestWebSearchServer_Initialize(t *testing.T) {
 		},
 	}
 
-	response := server.handleMessage(msg)
+	response := server.handleMessage(context.Background(), msg)
 
 	if response == nil {
 		t.Fatal("Expected response, got nil")
@@ -55,7 +58,7 @@ func TestWebSearchServer_ToolsList(t *testing.T) {
 		Method:  "tools/list",
 	}
 
-	response := server.handleMessage(msg)
+	response := server.handleMessage(context.Background(), msg)
 
 	if response == nil {
 		t.Fatal("Expected response, got nil")
@@ -93,7 +96,7 @@ func TestWebSearchServer_Ping(t *testing.T) {
 		Method:  "ping",
 	}
 
-	response := server.handleMessage(msg)
+	response := server.handleMessage(context.Background(), msg)
 
 	if response == nil {
 		t.Fatal("Expected response, got nil")
@@ -117,7 +120,7 @@ func TestWebSearchServer_InvalidMethod(t *testing.T) {
 		Method:  "invalid_method",
 	}
 
-	response := server.handleMessage(msg)
+	response := server.handleMessage(context.Background(), msg)
 
 	if response == nil {
 		t.Fatal("Expected response, got nil")
@@ -231,3 +234,113 @@ func TestHealthEndpoint(t *testing.T) {
 
 // Note: WebSocket integration tests removed as stdio is now the default mode
 // HTTP mode is still available for testing via --http flag
+
+func TestWebSearchServer_StructuredContent(t *testing.T) {
+	server := newTestServer(&stubProvider{name: "stub", results: []SearchResult{
+		{Title: "Go", URL: "https://go.dev/?utm_source=x", Rank: 1},
+	}})
+	call := MCPMessage{
+		JSONRPC: "2.0",
+		ID:      2,
+		Method:  "tools/call",
+		Params: map[string]interface{}{
+			"name":      "web_search",
+			"arguments": map[string]interface{}{"query": "golang"},
+		},
+	}
+
+	ctx := initializeSession(t, server, "2025-06-18")
+	result := server.handleMessage(ctx, call).Result.(map[string]interface{})
+	structured, ok := result["structuredContent"].(*SearchResponse)
+	if !ok {
+		t.Fatalf("Expected structuredContent, got %+v", result)
+	}
+	if structured.Count != 1 || structured.Results[0].CanonicalURL != "https://go.dev" {
+		t.Errorf("Unexpected structured results: %+v", structured.Results)
+	}
+	if len(structured.Providers) != 1 || structured.Providers[0] != "stub" {
+		t.Errorf("Expected provider stub, got %v", structured.Providers)
+	}
+	if _, ok := result["content"]; !ok {
+		t.Error("Expected text content alongside structuredContent")
+	}
+
+	ctx = initializeSession(t, server, "2025-03-26")
+	result = server.handleMessage(ctx, call).Result.(map[string]interface{})
+	if _, ok := result["structuredContent"]; ok {
+		t.Error("Expected no structuredContent before 2025-06-18")
+	}
+
+	tools := server.handleMessage(ctx, MCPMessage{JSONRPC: "2.0", ID: 3, Method: "tools/list"}).Result.(map[string]interface{})["tools"].([]Tool)
+	if tools[0].OutputSchema != nil {
+		t.Error("Expected no outputSchema before 2025-06-18")
+	}
+}
+
+func TestWebSearchServer_SearchFailureIsToolError(t *testing.T) {
+	server := newTestServer(&stubProvider{name: "broken", err: errors.New("boom")})
+	response := server.handleMessage(context.Background(), MCPMessage{
+		JSONRPC: "2.0",
+		ID:      1,
+		Method:  "tools/call",
+		Params: map[string]interface{}{
+			"name":      "web_search",
+			"arguments": map[string]interface{}{"query": "golang"},
+		},
+	})
+
+	if response.Error != nil {
+		t.Fatalf("Expected a tool result, got JSON-RPC error %+v", response.Error)
+	}
+	result := response.Result.(map[string]interface{})
+	if result["isError"] != true {
+		t.Errorf("Expected isError, got %+v", result)
+	}
+	text := result["content"].([]map[string]interface{})[0]["text"].(string)
+	if !strings.Contains(text, "broken: boom") || !strings.Contains(text, "SEARCH_PROVIDER") {
+		t.Errorf("Expected the failing provider and a remedy in %q", text)
+	}
+}
+
+func TestWebSearchServer_MaxResultsClamped(t *testing.T) {
+	stub := &stubProvider{name: "stub", results: []SearchResult{{Title: "Go", URL: "https://go.dev/", Rank: 1}}}
+	server := newTestServer(stub)
+
+	for _, tt := range []struct {
+		arg  interface{}
+		want int
+	}{
+		{-1, 1},
+		{0, 1},
+		{500, 20},
+		{5, 5},
+		{nil, 10},
+	} {
+		args := map[string]interface{}{"query": fmt.Sprintf("golang %v", tt.arg)}
+		if tt.arg != nil {
+			args["max_results"] = float64(tt.arg.(int))
+		}
+		response := server.handleMessage(context.Background(), MCPMessage{
+			JSONRPC: "2.0",
+			ID:      1,
+			Method:  "tools/call",
+			Params:  map[string]interface{}{"name": "web_search", "arguments": args},
+		})
+		if response.Error != nil {
+			t.Fatalf("max_results=%v: unexpected error %+v", tt.arg, response.Error)
+		}
+		if stub.last.MaxResults != tt.want {
+			t.Errorf("max_results=%v: expected the provider to get %d, got %d", tt.arg, tt.want, stub.last.MaxResults)
+		}
+	}
+}
+
+func TestQuery_LimitIgnoresNonPositive(t *testing.T) {
+	results := []SearchResult{{Rank: 1}, {Rank: 2}}
+	if got := (Query{MaxResults: -1}).limit(results); len(got) != 2 {
+		t.Errorf("Expected no limit for -1, got %d results", len(got))
+	}
+	if got := (Query{MaxResults: 1}).limit(results); len(got) != 1 {
+		t.Errorf("Expected 1 result, got %d", len(got))
+	}
+}
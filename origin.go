@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// originPolicy decides which browser origins may use the MCP endpoints. The
+// server listens on every interface, so without a check any web page the
+// user visits could call tools on it, directly or through DNS rebinding.
+// Pages served from a loopback host are always allowed; anything else must
+// be listed in MCP_ALLOWED_ORIGINS.
+type originPolicy struct {
+	allowAll bool
+	origins  map[string]bool
+}
+
+// originPolicyFromEnv reads MCP_ALLOWED_ORIGINS, a comma-separated list of
+// origins such as https://app.example.com, or "*" to allow any origin
+func originPolicyFromEnv() originPolicy {
+	p := originPolicy{origins: make(map[string]bool)}
+	for _, origin := range strings.Split(os.Getenv("MCP_ALLOWED_ORIGINS"), ",") {
+		origin = strings.TrimSpace(origin)
+		switch origin {
+		case "":
+		case "*":
+			p.allowAll = true
+		default:
+			p.origins[normalizeOrigin(origin)] = true
+		}
+	}
+	return p
+}
+
+// allowed reports whether r may be served. Requests without an Origin
+// header come from non-browser clients and are always allowed.
+func (p originPolicy) allowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || p.allowAll || p.origins[normalizeOrigin(origin)] {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	// Match the Origin itself, never the Host header: under DNS rebinding
+	// both name the attacker's domain
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func normalizeOrigin(origin string) string {
+	return strings.TrimSuffix(strings.ToLower(origin), "/")
+}
+
+// checkOrigin wraps an MCP endpoint with the origin policy
+func (s *WebSearchServer) checkOrigin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.origins.allowed(r) {
+			s.logger.Printf("Rejected request from origin %q", r.Header.Get("Origin"))
+			writeHTTPError(w, http.StatusForbidden, -32600, "Origin not allowed")
+			return
+		}
+		next(w, r)
+	}
+}
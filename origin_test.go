@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOriginPolicy(t *testing.T) {
+	t.Setenv("MCP_ALLOWED_ORIGINS", "https://App.example.com/, http://10.0.0.5:3000")
+	policy := originPolicyFromEnv()
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{"http://localhost:3000", true},
+		{"http://app.localhost", true},
+		{"http://127.0.0.1:8080", true},
+		{"http://[::1]:8080", true},
+		{"https://app.example.com", true},
+		{"http://10.0.0.5:3000", true},
+		{"http://10.0.0.5:3001", false},
+		{"https://evil.example", false},
+		{"http://localhost.evil.example", false},
+		{"null", false},
+		{"file://", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := policy.allowed(r); got != tt.want {
+			t.Errorf("allowed(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+
+	t.Setenv("MCP_ALLOWED_ORIGINS", "*")
+	r := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+	r.Header.Set("Origin", "https://evil.example")
+	if !originPolicyFromEnv().allowed(r) {
+		t.Error("Expected * to allow any origin")
+	}
+}
+
+func TestHTTPTransports_RejectForeignOrigin(t *testing.T) {
+	server := NewWebSearchServer()
+	ts := httptest.NewServer(server.httpHandler())
+	defer ts.Close()
+
+	for _, path := range []string{"/mcp", "/sse", "/messages?sessionId=x"} {
+		method := http.MethodPost
+		if path == "/sse" {
+			method = http.MethodGet
+		}
+		req, _ := http.NewRequest(method, ts.URL+path, nil)
+		req.Header.Set("Origin", "https://evil.example")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("%s: expected 403 for a foreign origin, got %d", path, resp.StatusCode)
+		}
+	}
+
+	// Clients that send no Origin, like this one, are unaffected
+	resp := postMCP(t, ts.URL+"/mcp", "", "application/json, text/event-stream", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 without an Origin header, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// parseGolden is the expected outcome of parsing one saved page: the
+// results, or the kind of error the page should produce
+type parseGolden struct {
+	Results []SearchResult `json:"results"`
+	Error   ErrorKind      `json:"error,omitempty"`
+}
+
+// TestParsers runs each provider's parser over the saved pages in
+// testdata/<provider>/ and compares the outcome with the .golden file next to
+// each page. Run with -update after adding a page or changing a parser, and
+// review the diff.
+func TestParsers(t *testing.T) {
+	parsers := []struct {
+		name  string
+		parse func(io.Reader) ([]SearchResult, error)
+	}{
+		{"duckduckgo", NewDuckDuckGoProvider(builtinScrapers()).parse},
+		{"mojeek", NewMojeekProvider(nil, builtinScrapers()).parse},
+		{"wikipedia", NewWikipediaProvider().parse},
+	}
+
+	for _, p := range parsers {
+		pages, err := filepath.Glob(filepath.Join("testdata", p.name, "*"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, page := range pages {
+			if filepath.Ext(page) == ".golden" {
+				continue
+			}
+			found = true
+			name := strings.TrimSuffix(filepath.Base(page), filepath.Ext(page))
+			t.Run(p.name+"/"+name, func(t *testing.T) {
+				checkParserGolden(t, p.parse, page)
+			})
+		}
+		if !found {
+			t.Errorf("No saved pages for %s in testdata/%s", p.name, p.name)
+		}
+	}
+}
+
+func checkParserGolden(t *testing.T, parse func(io.Reader) ([]SearchResult, error), page string) {
+	t.Helper()
+	f, err := os.Open(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var got parseGolden
+	results, err := parse(f)
+	if err != nil {
+		var pe *ProviderError
+		if !errors.As(err, &pe) {
+			t.Fatalf("Expected a ProviderError, got %T: %v", err, err)
+		}
+		got.Error = pe.Kind
+	} else {
+		got.Results = results
+		if got.Results == nil {
+			got.Results = []SearchResult{}
+		}
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(got); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	goldenPath := strings.TrimSuffix(page, filepath.Ext(page)) + ".golden"
+	if *update {
+		if err := os.WriteFile(goldenPath, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("Missing golden file (run go test -run TestParsers -update): %v", err)
+	}
+	if !bytes.Equal(data, want) {
+		t.Errorf("Parsed %s does not match %s\ngot:\n%s\nwant:\n%s", page, goldenPath, data, want)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"net/http"
+)
+
+// Published MCP revisions this server speaks, newest first. Revisions are
+// dates, so they order correctly as strings.
+var supportedProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+const (
+	latestProtocolVersion = "2025-06-18"
+	oldestProtocolVersion = "2024-11-05"
+
+	// protocolVersionHeader is sent by 2025-06-18 clients on every HTTP
+	// request after initialize
+	protocolVersionHeader = "Mcp-Protocol-Version"
+)
+
+// First revision that has each version-dependent feature
+const (
+	versionToolAnnotations  = "2025-03-26"
+	versionStructuredOutput = "2025-06-18"
+	versionToolTitles       = "2025-06-18"
+	// Batching was added in 2025-03-26 and removed again in 2025-06-18
+	versionNoBatching = "2025-06-18"
+)
+
+func isSupportedProtocolVersion(v string) bool {
+	for _, supported := range supportedProtocolVersions {
+		if v == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateProtocolVersion answers a client's requested revision: the same
+// revision when supported, otherwise the latest one we speak, leaving it to
+// the client to disconnect if it cannot use that
+func negotiateProtocolVersion(requested string) string {
+	if isSupportedProtocolVersion(requested) {
+		return requested
+	}
+	return latestProtocolVersion
+}
+
+// protocolVersionFor returns the revision negotiated for the session ctx
+// belongs to. Sessions that have not initialized get the oldest feature set;
+// messages handled outside a transport get the newest.
+func protocolVersionFor(ctx context.Context) string {
+	sess := sessionFromContext(ctx)
+	if sess == nil {
+		return latestProtocolVersion
+	}
+	if v := sess.ProtocolVersion(); v != "" {
+		return v
+	}
+	return oldestProtocolVersion
+}
+
+// supportsFeature reports whether the negotiated revision includes a feature
+// first published in revision since
+func supportsFeature(ctx context.Context, since string) bool {
+	return protocolVersionFor(ctx) >= since
+}
+
+// supportsBatching reports whether JSON-RPC batches may be used. Sessions
+// still negotiating keep accepting them so existing clients are unaffected.
+func supportsBatching(ctx context.Context) bool {
+	sess := sessionFromContext(ctx)
+	if sess == nil {
+		return true
+	}
+	v := sess.ProtocolVersion()
+	return v == "" || v < versionNoBatching
+}
+
+// checkProtocolVersionHeader validates the Mcp-Protocol-Version header of a
+// Streamable HTTP request against the session. Clients before 2025-06-18 do
+// not send it, so a missing header is accepted.
+func checkProtocolVersionHeader(r *http.Request, sess *Session) (string, bool) {
+	v := r.Header.Get(protocolVersionHeader)
+	if v == "" {
+		return "", true
+	}
+	if !isSupportedProtocolVersion(v) {
+		return "Unsupported protocol version: " + v, false
+	}
+	if negotiated := sess.ProtocolVersion(); negotiated != "" && negotiated != v {
+		return "Protocol version " + v + " does not match negotiated version " + negotiated, false
+	}
+	return "", true
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateProtocolVersion(t *testing.T) {
+	tests := map[string]string{
+		"2024-11-05": "2024-11-05",
+		"2025-03-26": "2025-03-26",
+		"2025-06-18": "2025-06-18",
+		"2099-01-01": latestProtocolVersion,
+		"":           latestProtocolVersion,
+	}
+	for requested, want := range tests {
+		if got := negotiateProtocolVersion(requested); got != want {
+			t.Errorf("negotiateProtocolVersion(%q) = %q, want %q", requested, got, want)
+		}
+	}
+}
+
+func initializeSession(t *testing.T, server *WebSearchServer, version string) context.Context {
+	t.Helper()
+	sess := NewSession()
+	ctx := withSession(context.Background(), sess)
+	server.handleMessage(ctx, MCPMessage{
+		JSONRPC: "2.0",
+		ID:      1,
+		Method:  "initialize",
+		Params:  map[string]interface{}{"protocolVersion": version},
+	})
+	if got := sess.ProtocolVersion(); got != version {
+		t.Fatalf("Expected session to negotiate %s, got %q", version, got)
+	}
+	return ctx
+}
+
+func TestToolsList_AnnotationsFollowProtocolVersion(t *testing.T) {
+	server := NewWebSearchServer()
+
+	for version, wantAnnotations := range map[string]bool{
+		"2024-11-05": false,
+		"2025-03-26": true,
+		"2025-06-18": true,
+	} {
+		ctx := initializeSession(t, server, version)
+		response := server.handleMessage(ctx, MCPMessage{JSONRPC: "2.0", ID: 2, Method: "tools/list"})
+		tools := response.Result.(map[string]interface{})["tools"].([]Tool)
+		if got := tools[0].Annotations != nil; got != wantAnnotations {
+			t.Errorf("%s: expected annotations %v, got %+v", version, wantAnnotations, tools[0].Annotations)
+		}
+		if got := tools[0].Title != ""; got != (version >= versionToolTitles) {
+			t.Errorf("%s: unexpected tool title %q", version, tools[0].Title)
+		}
+	}
+}
+
+func TestHandleBatch_RejectedAfter20250618(t *testing.T) {
+	server := NewWebSearchServer()
+	ctx := initializeSession(t, server, "2025-06-18")
+
+	reply, ok := server.handleBatch(ctx, []byte(`[{"jsonrpc":"2.0","id":2,"method":"ping"}]`), make(chan struct{}, 4)).(*MCPMessage)
+	if !ok || reply.Error == nil || reply.Error.Code != -32600 {
+		t.Errorf("Expected a single -32600 for a batch, got %+v", reply)
+	}
+}
+
+func TestCheckProtocolVersionHeader(t *testing.T) {
+	sess := NewSession()
+	sess.markInitialized("2025-06-18")
+
+	for header, wantOK := range map[string]bool{
+		"":           true,
+		"2025-06-18": true,
+		"2025-03-26": false,
+		"1999-01-01": false,
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+		if header != "" {
+			r.Header.Set(protocolVersionHeader, header)
+		}
+		if _, ok := checkProtocolVersionHeader(r, sess); ok != wantOK {
+			t.Errorf("Header %q: expected ok=%v", header, wantOK)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// Query describes a single search handed to a SearchProvider
+type Query struct {
+	Text       string
+	MaxResults int
+}
+
+// Bounds for the web_search max_results argument
+const (
+	defaultMaxResults = 10
+	maxMaxResults     = 20
+)
+
+// limit trims results to q.MaxResults; a MaxResults of 0 or less means no
+// limit
+func (q Query) limit(results []SearchResult) []SearchResult {
+	if q.MaxResults > 0 && len(results) > q.MaxResults {
+		return results[:q.MaxResults]
+	}
+	return results
+}
+
+// ProviderCapabilities describes what a provider can do so the dispatcher
+// can plan around it without knowing the concrete type
+type ProviderCapabilities struct {
+	// Scraped is true when results are parsed out of HTML pages rather than
+	// returned by a structured API
+	Scraped bool `json:"scraped"`
+	// Snippets is true when results usually carry a description
+	Snippets bool `json:"snippets"`
+	// MaxResults is the most results one request can return (0 = no limit)
+	MaxResults int `json:"max_results"`
+}
+
+// SearchProvider is a single search backend
+type SearchProvider interface {
+	Name() string
+	Capabilities() ProviderCapabilities
+	Search(ctx context.Context, q Query) (*SearchResponse, error)
+}
+
+// ProviderRegistry holds the known providers, their aliases and the order
+// in which enabled providers are tried
+type ProviderRegistry struct {
+	mu        sync.RWMutex
+	providers map[string]SearchProvider
+	aliases   map[string]string
+	order     []string
+	disabled  map[string]bool
+}
+
+func NewProviderRegistry() *ProviderRegistry {
+	return &ProviderRegistry{
+		providers: make(map[string]SearchProvider),
+		aliases:   make(map[string]string),
+		disabled:  make(map[string]bool),
+	}
+}
+
+// Register adds a provider to the end of the order. Aliases resolve to the
+// provider's name in Get and SetOrder.
+func (r *ProviderRegistry) Register(p SearchProvider, aliases ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := strings.ToLower(p.Name())
+	if _, exists := r.providers[name]; exists {
+		return fmt.Errorf("provider %q already registered", name)
+	}
+	for _, alias := range aliases {
+		alias = strings.ToLower(alias)
+		if _, exists := r.providers[alias]; exists {
+			return fmt.Errorf("alias %q collides with a provider name", alias)
+		}
+		if owner, exists := r.aliases[alias]; exists {
+			return fmt.Errorf("alias %q already registered for %q", alias, owner)
+		}
+	}
+
+	r.providers[name] = p
+	for _, alias := range aliases {
+		r.aliases[strings.ToLower(alias)] = name
+	}
+	r.order = append(r.order, name)
+	return nil
+}
+
+func (r *ProviderRegistry) resolve(name string) (string, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if _, ok := r.providers[name]; ok {
+		return name, true
+	}
+	if target, ok := r.aliases[name]; ok {
+		return target, true
+	}
+	return "", false
+}
+
+// Get returns the provider registered under name or one of its aliases
+func (r *ProviderRegistry) Get(name string) (SearchProvider, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	resolved, ok := r.resolve(name)
+	if !ok {
+		return nil, false
+	}
+	return r.providers[resolved], true
+}
+
+// SetOrder enables exactly the named providers, in the given order.
+// Providers not listed stay registered but are disabled.
+func (r *ProviderRegistry) SetOrder(names []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order := make([]string, 0, len(names))
+	seen := make(map[string]bool)
+	for _, n := range names {
+		if strings.TrimSpace(n) == "" {
+			continue
+		}
+		resolved, ok := r.resolve(n)
+		if !ok {
+			return fmt.Errorf("unknown provider %q", n)
+		}
+		if seen[resolved] {
+			continue
+		}
+		seen[resolved] = true
+		order = append(order, resolved)
+	}
+	if len(order) == 0 {
+		return fmt.Errorf("no providers selected")
+	}
+
+	// Keep unlisted providers at the tail so they can be re-enabled later
+	for _, n := range r.order {
+		if !seen[n] {
+			order = append(order, n)
+		}
+	}
+	r.order = order
+	r.disabled = make(map[string]bool)
+	for name := range r.providers {
+		if !seen[name] {
+			r.disabled[name] = true
+		}
+	}
+	return nil
+}
+
+// SetEnabled toggles a single provider without changing the order
+func (r *ProviderRegistry) SetEnabled(name string, enabled bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	resolved, ok := r.resolve(name)
+	if !ok {
+		return fmt.Errorf("unknown provider %q", name)
+	}
+	if enabled {
+		delete(r.disabled, resolved)
+	} else {
+		r.disabled[resolved] = true
+	}
+	return nil
+}
+
+// Enabled returns the enabled providers in order
+func (r *ProviderRegistry) Enabled() []SearchProvider {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make([]SearchProvider, 0, len(r.order))
+	for _, name := range r.order {
+		if !r.disabled[name] {
+			out = append(out, r.providers[name])
+		}
+	}
+	return out
+}
+
+// Names returns every registered provider name in order
+func (r *ProviderRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]string(nil), r.order...)
+}
+
+// newDefaultRegistry registers the built-in providers in the default auto
+// order (Mojeek, DuckDuckGo, Wikipedia), then any HTML engines defined in
+// the scraper rules file, and applies SEARCH_PROVIDERS, a comma-separated list
+// that selects and orders the enabled providers.
+func newDefaultRegistry(logger *log.Logger, scrapers *scraperSet, providerList string) (*ProviderRegistry, error) {
+	registry := NewProviderRegistry()
+	builtins := []struct {
+		provider SearchProvider
+		aliases  []string
+	}{
+		{NewMojeekProvider(logger, scrapers), nil},
+		{NewDuckDuckGoProvider(scrapers), []string{"ddg"}},
+		{NewWikipediaProvider(), []string{"wiki"}},
+	}
+	for _, b := range builtins {
+		if err := registry.Register(b.provider, b.aliases...); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range scrapers.engines() {
+		if _, taken := registry.Get(name); taken {
+			logger.Printf("Ignoring url for scraper engine %q: the name belongs to a built-in provider", name)
+			continue
+		}
+		p, err := NewHTMLProvider(name, scrapers)
+		if err != nil {
+			return nil, err
+		}
+		if err := registry.Register(p); err != nil {
+			return nil, err
+		}
+	}
+
+	if strings.TrimSpace(providerList) != "" {
+		if err := registry.SetOrder(strings.Split(providerList, ",")); err != nil {
+			return registry, fmt.Errorf("invalid SEARCH_PROVIDERS: %w", err)
+		}
+	}
+	return registry, nil
+}
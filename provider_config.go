@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// providerEndpoint is where and how a provider sends its requests. Every
+// field can be overridden per provider, for self-hosted mirrors, proxies or
+// local stand-ins in tests.
+type providerEndpoint struct {
+	// URL is the search endpoint; query parameters are added to it
+	URL string
+	// Header is set on every request, after the provider's own headers
+	Header  http.Header
+	Timeout time.Duration
+}
+
+// endpointFromEnv applies SEARCH_<NAME>_URL, SEARCH_<NAME>_HEADERS and
+// SEARCH_<NAME>_TIMEOUT to a provider's default endpoint
+func endpointFromEnv(name string, def providerEndpoint) providerEndpoint {
+	prefix := "SEARCH_" + strings.ToUpper(name) + "_"
+	e := def
+
+	if v := strings.TrimSpace(os.Getenv(prefix + "URL")); v != "" {
+		if u, err := url.Parse(v); err != nil || u.Scheme == "" || u.Host == "" {
+			log.Printf("Ignoring invalid %sURL=%q: expected an absolute URL", prefix, v)
+		} else {
+			e.URL = v
+		}
+	}
+	if v := os.Getenv(prefix + "HEADERS"); v != "" {
+		header, err := parseHeaders(v)
+		if err != nil {
+			log.Printf("Ignoring invalid %sHEADERS: %v", prefix, err)
+		} else {
+			e.Header = header
+		}
+	}
+	e.Timeout = envDuration(prefix+"TIMEOUT", def.Timeout)
+	return e
+}
+
+// parseHeaders parses "Name: value" pairs separated by "|", e.g.
+// "Authorization: Bearer x|X-Forwarded-For: 10.0.0.1". A pipe is used because
+// header values commonly contain commas and semicolons.
+func parseHeaders(spec string) (http.Header, error) {
+	header := make(http.Header)
+	for _, pair := range strings.Split(spec, "|") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid header %q: expected Name: value", strings.TrimSpace(pair))
+		}
+		header.Add(name, strings.TrimSpace(value))
+	}
+	return header, nil
+}
+
+// newRequest builds a GET request for the endpoint with params added to its
+// query string and the configured headers applied
+func (e providerEndpoint) newRequest(ctx context.Context, params url.Values, setDefaults func(*http.Request)) (*http.Request, error) {
+	u, err := url.Parse(e.URL)
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+	for k, vs := range params {
+		query[k] = vs
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if setDefaults != nil {
+		setDefaults(req)
+	}
+	for k, vs := range e.Header {
+		req.Header[k] = vs
+	}
+	return req, nil
+}
+
+// base returns the scheme and host of the endpoint, for resolving relative
+// links found in its pages
+func (e providerEndpoint) base() *url.URL {
+	u, err := url.Parse(e.URL)
+	if err != nil {
+		return &url.URL{}
+	}
+	return &url.URL{Scheme: u.Scheme, Host: u.Host}
+}
+
+func (e providerEndpoint) client() *http.Client {
+	return &http.Client{Timeout: e.Timeout, Transport: providerTransport()}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// standIn serves body for every request and passes each request to seen
+func standIn(t *testing.T, body string, seen func(*http.Request)) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if seen != nil {
+			seen(r)
+		}
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDuckDuckGoProvider_StandIn(t *testing.T) {
+	var query string
+	srv := standIn(t, `<html><body>
+<div class="result result--ad"><a class="result__a" href="https://ads.example.com">Ad</a></div>
+<div class="result">
+  <h2 class="result__title"><a class="result__a" href="/l/?uddg=https%3A%2F%2Fgo.dev%2Fdoc%2F&rut=x">Go docs</a></h2>
+  <a class="result__snippet">Documentation for Go</a>
+</div>
+</body></html>`, func(r *http.Request) { query = r.URL.Query().Get("q") })
+	t.Setenv("SEARCH_DUCKDUCKGO_URL", srv.URL+"/html/")
+
+	res, err := NewDuckDuckGoProvider(builtinScrapers()).Search(context.Background(), Query{Text: "go docs", MaxResults: 5})
+	if err != nil {
+		t.Fatalf("Expected results from the stand-in, got %v", err)
+	}
+	if query != "go docs" {
+		t.Errorf("Expected the query to reach the stand-in, got %q", query)
+	}
+	if res.Count != 1 || res.Results[0].URL != "https://go.dev/doc/" || res.Results[0].Description != "Documentation for Go" {
+		t.Errorf("Unexpected results: %+v", res.Results)
+	}
+}
+
+func TestMojeekProvider_StandIn(t *testing.T) {
+	srv := standIn(t, `<html><body><ul id="results">
+<li class="result"><a href="/redirect/go">Go</a><p class="s">The Go language</p></li>
+</ul></body></html>`, nil)
+	t.Setenv("SEARCH_MOJEEK_URL", srv.URL+"/search")
+
+	res, err := NewMojeekProvider(nil, builtinScrapers()).Search(context.Background(), Query{Text: "golang", MaxResults: 5})
+	if err != nil {
+		t.Fatalf("Expected results from the stand-in, got %v", err)
+	}
+	// Relative links resolve against the configured host, not mojeek.com
+	if res.Count != 1 || res.Results[0].URL != srv.URL+"/redirect/go" {
+		t.Errorf("Unexpected results: %+v", res.Results)
+	}
+}
+
+func TestWikipediaProvider_Mirror(t *testing.T) {
+	const search = `"search":[{"title":"Go (programming language)","snippet":"Go is a language"}]`
+	tests := []struct {
+		name    string
+		apiPath string
+		general string
+		want    string
+	}{
+		{
+			name:    "short URLs",
+			apiPath: "/w/api.php",
+			general: `"general":{"server":"//%s","articlepath":"/wiki/$1"},`,
+			want:    "/wiki/Go_%28programming_language%29",
+		},
+		{
+			name:    "stock MediaWiki",
+			apiPath: "/mediawiki/api.php",
+			general: `"general":{"server":"http://%s","articlepath":"/mediawiki/index.php?title=$1"},`,
+			want:    "/mediawiki/index.php?title=Go_%28programming_language%29",
+		},
+		{
+			name:    "no siteinfo",
+			apiPath: "/mediawiki/api.php",
+			want:    "/mediawiki/index.php?title=Go_%28programming_language%29",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path, srsearch string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path, srsearch = r.URL.Path, r.URL.Query().Get("srsearch")
+				general := tt.general
+				if general != "" {
+					general = fmt.Sprintf(general, r.Host)
+				}
+				io.WriteString(w, `{"query":{`+general+search+`}}`)
+			}))
+			defer srv.Close()
+			t.Setenv("SEARCH_WIKIPEDIA_URL", srv.URL+tt.apiPath)
+
+			res, err := NewWikipediaProvider().Search(context.Background(), Query{Text: "golang", MaxResults: 2})
+			if err != nil {
+				t.Fatalf("Expected results from the mirror, got %v", err)
+			}
+			if path != tt.apiPath || srsearch != "golang" {
+				t.Errorf("Expected an API request for golang, got %s srsearch=%q", path, srsearch)
+			}
+			if res.Count != 1 || res.Results[0].URL != srv.URL+tt.want {
+				t.Errorf("Expected %s on the mirror, got %+v", srv.URL+tt.want, res.Results)
+			}
+		})
+	}
+}
+
+func TestProviderEndpoint_HeadersAndTimeout(t *testing.T) {
+	var header http.Header
+	srv := standIn(t, `{"query":{"search":[]}}`, func(r *http.Request) { header = r.Header })
+	t.Setenv("SEARCH_WIKIPEDIA_URL", srv.URL+"/w/api.php")
+	t.Setenv("SEARCH_WIKIPEDIA_HEADERS", "Authorization: Bearer secret|User-Agent: mirror-client/1.0")
+	t.Setenv("SEARCH_WIKIPEDIA_TIMEOUT", "3s")
+
+	p := NewWikipediaProvider()
+	if p.client.Timeout != 3*time.Second {
+		t.Errorf("Expected a 3s timeout, got %v", p.client.Timeout)
+	}
+	if _, err := p.Search(context.Background(), Query{Text: "golang", MaxResults: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if header.Get("Authorization") != "Bearer secret" {
+		t.Errorf("Expected the configured Authorization header, got %q", header.Get("Authorization"))
+	}
+	// Configured headers win over the provider's defaults
+	if header.Get("User-Agent") != "mirror-client/1.0" {
+		t.Errorf("Expected the configured User-Agent, got %q", header.Get("User-Agent"))
+	}
+}
+
+func TestEndpointFromEnv_Invalid(t *testing.T) {
+	def := providerEndpoint{URL: "https://example.com/search", Timeout: time.Second}
+	t.Setenv("SEARCH_TEST_URL", "not a url")
+	t.Setenv("SEARCH_TEST_HEADERS", "missing colon")
+
+	e := endpointFromEnv("test", def)
+	if e.URL != def.URL || e.Header != nil || e.Timeout != def.Timeout {
+		t.Errorf("Expected invalid settings to be ignored, got %+v", e)
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	header, err := parseHeaders("X-Api-Key: a,b;c| Accept-Language : de |")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Get("X-Api-Key") != "a,b;c" || header.Get("Accept-Language") != "de" {
+		t.Errorf("Unexpected headers: %v", header)
+	}
+	if _, err := parseHeaders(": value"); err == nil {
+		t.Error("Expected an error for a header without a name")
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DuckDuckGoProvider scrapes the DuckDuckGo HTML endpoint
+type DuckDuckGoProvider struct {
+	client   *http.Client
+	endpoint providerEndpoint
+	scrapers *scraperSet
+}
+
+func NewDuckDuckGoProvider(scrapers *scraperSet) *DuckDuckGoProvider {
+	endpoint := endpointFromEnv("duckduckgo", providerEndpoint{
+		URL:     "https://html.duckduckgo.com/html/",
+		Timeout: 30 * time.Second,
+	})
+	return &DuckDuckGoProvider{client: endpoint.client(), endpoint: endpoint, scrapers: scrapers}
+}
+
+func (p *DuckDuckGoProvider) Name() string { return "duckduckgo" }
+
+func (p *DuckDuckGoProvider) Capabilities() ProviderCapabilities {
+	return ProviderCapabilities{Scraped: true, Snippets: true, MaxResults: 30}
+}
+
+func (p *DuckDuckGoProvider) Search(ctx context.Context, q Query) (*SearchResponse, error) {
+	req, err := p.endpoint.newRequest(ctx, url.Values{"q": {q.Text}}, setBrowserHeaders)
+	if err != nil {
+		return nil, newProviderError(p.Name(), ErrOther, fmt.Errorf("failed to create request: %w", err))
+	}
+	// Do not set Accept-Encoding manually; let Go auto-handle gzip to avoid manual decompression
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, requestError(p.Name(), err)
+	}
+	defer resp.Body.Close()
+	// DDG serves its anomaly (CAPTCHA) page with 202, so let it through to
+	// block page detection
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		return nil, statusError(p.Name(), resp)
+	}
+
+	results, err := p.parse(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	results = q.limit(results)
+	return &SearchResponse{Query: q.Text, Results: results, Count: len(results)}, nil
+}
+
+// parse extracts the organic results from a DDG HTML results page using the
+// duckduckgo scraper rules
+func (p *DuckDuckGoProvider) parse(r io.Reader) ([]SearchResult, error) {
+	return p.scrapers.parse(p.Name(), p.endpoint.base(), r)
+}
+
+// setBrowserHeaders sets headers that mimic a desktop browser for scraped providers
+func setBrowserHeaders(req *http.Request) {
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ErrorKind classifies why a provider failed, so callers and stats can tell a
+// changed page layout from rate limiting without reading logs
+type ErrorKind string
+
+const (
+	ErrTimeout     ErrorKind = "timeout"
+	ErrRateLimited ErrorKind = "rate_limited"
+	ErrBlocked     ErrorKind = "blocked"
+	ErrUpstream    ErrorKind = "upstream"
+	ErrParse       ErrorKind = "parse"
+	ErrNetwork     ErrorKind = "network"
+	ErrOther       ErrorKind = "other"
+	// ErrUnavailable marks a provider skipped by its circuit breaker
+	ErrUnavailable ErrorKind = "unavailable"
+	// ErrThrottled marks a provider skipped because our own rate limit for
+	// it would have delayed the request too long
+	ErrThrottled ErrorKind = "throttled"
+)
+
+var errorKindDescriptions = map[ErrorKind]string{
+	ErrTimeout:     "timed out",
+	ErrRateLimited: "rate limited",
+	ErrBlocked:     "blocked the request",
+	ErrUpstream:    "upstream error",
+	ErrParse:       "could not parse the response",
+	ErrNetwork:     "unreachable",
+	ErrUnavailable: "unavailable",
+	ErrThrottled:   "local rate limit reached",
+}
+
+// ProviderError is the error every provider returns when a search fails
+type ProviderError struct {
+	Provider string    `json:"provider"`
+	Kind     ErrorKind `json:"kind"`
+	// StatusCode is the upstream HTTP status, when there was one
+	StatusCode int `json:"status_code,omitempty"`
+	// RetryAfter is how long the provider asked us to wait, if it said
+	RetryAfter time.Duration `json:"-"`
+	Err        error         `json:"-"`
+}
+
+func (e *ProviderError) Error() string {
+	desc, ok := errorKindDescriptions[e.Kind]
+	if !ok {
+		return fmt.Sprintf("%s: %v", e.Provider, e.Err)
+	}
+	return fmt.Sprintf("%s: %s: %v", e.Provider, desc, e.Err)
+}
+
+func (e *ProviderError) Unwrap() error { return e.Err }
+
+// MarshalJSON adds the message, which is not otherwise exported
+func (e *ProviderError) MarshalJSON() ([]byte, error) {
+	type plain ProviderError
+	return json.Marshal(struct {
+		*plain
+		Message string `json:"message"`
+	}{(*plain)(e), e.Error()})
+}
+
+func newProviderError(provider string, kind ErrorKind, err error) *ProviderError {
+	return &ProviderError{Provider: provider, Kind: kind, Err: err}
+}
+
+// requestError classifies an error from http.Client.Do. Cancellation by the
+// caller is not a provider failure and is returned unchanged.
+func requestError(provider string, err error) error {
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return newProviderError(provider, ErrTimeout, err)
+	}
+	return newProviderError(provider, ErrNetwork, fmt.Errorf("failed to perform search: %w", err))
+}
+
+// statusError classifies a non-200 response
+func statusError(provider string, resp *http.Response) *ProviderError {
+	kind := ErrUpstream
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		kind = ErrRateLimited
+	case resp.StatusCode == http.StatusForbidden:
+		kind = ErrBlocked
+	}
+	e := newProviderError(provider, kind, fmt.Errorf("search request failed with status: %d", resp.StatusCode))
+	e.StatusCode = resp.StatusCode
+	e.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+	return e
+}
+
+// asProviderError gives errors from providers that do not classify their own
+// failures a kind, so stats and tool results always have one
+func asProviderError(provider string, err error) error {
+	var pe *ProviderError
+	if errors.As(err, &pe) || errors.Is(err, context.Canceled) {
+		return err
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return newProviderError(provider, ErrTimeout, err)
+	}
+	return newProviderError(provider, ErrOther, err)
+}
+
+// providerErrors collects the provider failures wrapped in err, including
+// those joined by fallback and merge searches
+func providerErrors(err error) []*ProviderError {
+	switch e := err.(type) {
+	case *ProviderError:
+		return []*ProviderError{e}
+	case interface{ Unwrap() []error }:
+		var out []*ProviderError
+		for _, inner := range e.Unwrap() {
+			out = append(out, providerErrors(inner)...)
+		}
+		return out
+	case interface{ Unwrap() error }:
+		return providerErrors(e.Unwrap())
+	}
+	return nil
+}
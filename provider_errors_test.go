@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundTripFunc serves canned responses to a provider's http.Client
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func cannedResponse(status int, body string) roundTripFunc {
+	return func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: status,
+			Header:     make(http.Header),
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    r,
+		}, nil
+	}
+}
+
+func TestProviderErrors_Kinds(t *testing.T) {
+	tests := []struct {
+		name string
+		rt   roundTripFunc
+		want ErrorKind
+	}{
+		{"rate limited", cannedResponse(http.StatusTooManyRequests, ""), ErrRateLimited},
+		{"forbidden", cannedResponse(http.StatusForbidden, ""), ErrBlocked},
+		{"upstream", cannedResponse(http.StatusBadGateway, ""), ErrUpstream},
+		{"layout changed", cannedResponse(http.StatusOK, "<html><body><div class=results></div></body></html>"), ErrParse},
+		{"network", func(*http.Request) (*http.Response, error) { return nil, errors.New("connection refused") }, ErrNetwork},
+		{"timeout", func(*http.Request) (*http.Response, error) { return nil, context.DeadlineExceeded }, ErrTimeout},
+	}
+	for _, tt := range tests {
+		p := NewDuckDuckGoProvider(builtinScrapers())
+		p.client.Transport = tt.rt
+		_, err := p.Search(context.Background(), Query{Text: "q", MaxResults: 5})
+		var pe *ProviderError
+		if !errors.As(err, &pe) || pe.Kind != tt.want || pe.Provider != "duckduckgo" {
+			t.Errorf("%s: expected %s error from duckduckgo, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	p := NewDuckDuckGoProvider(builtinScrapers())
+	p.client.Transport = cannedResponse(http.StatusOK, `<html><body><div class="no-results">No results.</div></body></html>`)
+	if res, err := p.Search(context.Background(), Query{Text: "q", MaxResults: 5}); err != nil || res.Count != 0 {
+		t.Errorf("Expected an empty result for a genuine no-results page, got %v %v", res, err)
+	}
+}
+
+func TestWebSearchServer_ProviderErrorsSurfaced(t *testing.T) {
+	limited := &stubProvider{name: "limited", err: newProviderError("limited", ErrRateLimited, errors.New("429"))}
+	broken := &stubProvider{name: "broken", err: errors.New("boom")}
+	server := newTestServer(limited, broken)
+
+	response := server.handleMessage(context.Background(), MCPMessage{
+		JSONRPC: "2.0",
+		ID:      1,
+		Method:  "tools/call",
+		Params: map[string]interface{}{
+			"name":      "web_search",
+			"arguments": map[string]interface{}{"query": "golang"},
+		},
+	})
+	result := response.Result.(map[string]interface{})
+	failures := result["_meta"].(map[string]interface{})["errors"].([]*ProviderError)
+	if len(failures) != 2 || failures[0].Kind != ErrRateLimited || failures[1].Kind != ErrOther {
+		t.Errorf("Expected both provider failures with kinds, got %+v", failures)
+	}
+	text := result["content"].([]map[string]interface{})[0]["text"].(string)
+	if !strings.Contains(text, "limited: rate limited") || !strings.Contains(text, "Wait a few minutes") {
+		t.Errorf("Expected rate limit details and remedy in %q", text)
+	}
+
+	counts := server.stats.GetStats()["provider_errors"].(map[ErrorKind]int64)
+	if counts[ErrRateLimited] != 1 || counts[ErrOther] != 1 {
+		t.Errorf("Expected per-kind counts, got %v", counts)
+	}
+}
+
+func TestProviderBackoff_BlockPage(t *testing.T) {
+	p := NewDuckDuckGoProvider(builtinScrapers())
+	p.client.Transport = cannedResponse(http.StatusAccepted, `<html><body><div class="anomaly-modal__modal">Unfortunately, bots use DuckDuckGo too.</div></body></html>`)
+	good := &stubProvider{name: "good", results: []SearchResult{{Title: "Hit", URL: "https://example.com", Rank: 1}}}
+	server := newTestServer(p, good)
+
+	res, err := server.performWebSearch(context.Background(), "q", 5)
+	if err != nil || res.Providers[0] != "good" {
+		t.Fatalf("Expected fallback to the good provider, got %v %v", res, err)
+	}
+	if server.stats.GetStats()["provider_errors"].(map[ErrorKind]int64)[ErrBlocked] != 1 {
+		t.Error("Expected the block page to be counted as blocked")
+	}
+	if _, benched := server.backoff.benchedUntil("duckduckgo"); !benched {
+		t.Fatal("Expected duckduckgo to be out of rotation")
+	}
+	if rotation := server.rotation(); len(rotation) != 1 || rotation[0].Name() != "good" {
+		t.Errorf("Expected only good in rotation, got %d providers", len(rotation))
+	}
+}
+
+func TestProviderBackoff_Exponential(t *testing.T) {
+	b := newProviderBackoff(time.Minute, 5*time.Minute)
+	for i, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute} {
+		if got := b.block("p"); got != want {
+			t.Errorf("Block %d: expected %s, got %s", i+1, want, got)
+		}
+	}
+	b.reset("p")
+	if got := b.block("p"); got != time.Minute {
+		t.Errorf("Expected backoff to restart after reset, got %s", got)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// HTMLProvider scrapes an engine defined entirely in the scraper rules file.
+// Its selectors follow the file as it changes; its URL is read once at
+// startup.
+type HTMLProvider struct {
+	name     string
+	client   *http.Client
+	endpoint providerEndpoint
+	scrapers *scraperSet
+	caps     ProviderCapabilities
+}
+
+// NewHTMLProvider returns the provider for an engine that scrapers defines
+func NewHTMLProvider(name string, scrapers *scraperSet) (*HTMLProvider, error) {
+	rules, ok := scrapers.get(name)
+	if !ok {
+		return nil, fmt.Errorf("no scraper rules for %s", name)
+	}
+	endpoint := endpointFromEnv(name, providerEndpoint{URL: rules.URL, Timeout: 30 * time.Second})
+	return &HTMLProvider{
+		name:     name,
+		client:   endpoint.client(),
+		endpoint: endpoint,
+		scrapers: scrapers,
+		caps:     ProviderCapabilities{Scraped: true, Snippets: len(rules.Snippet) > 0, MaxResults: rules.MaxResults},
+	}, nil
+}
+
+func (p *HTMLProvider) Name() string { return p.name }
+
+func (p *HTMLProvider) Capabilities() ProviderCapabilities { return p.caps }
+
+func (p *HTMLProvider) Search(ctx context.Context, q Query) (*SearchResponse, error) {
+	rules, ok := p.scrapers.get(p.name)
+	if !ok {
+		return nil, newProviderError(p.Name(), ErrOther, fmt.Errorf("no scraper rules for %s", p.name))
+	}
+	params := make(url.Values)
+	for k, v := range rules.Params {
+		params.Set(k, v)
+	}
+	queryParam := rules.QueryParam
+	if queryParam == "" {
+		queryParam = "q"
+	}
+	params.Set(queryParam, q.Text)
+
+	req, err := p.endpoint.newRequest(ctx, params, setBrowserHeaders)
+	if err != nil {
+		return nil, newProviderError(p.Name(), ErrOther, fmt.Errorf("failed to create request: %w", err))
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, requestError(p.Name(), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(p.Name(), resp)
+	}
+
+	results, err := rules.parse(p.Name(), p.endpoint.base(), resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	results = q.limit(results)
+	return &SearchResponse{Query: q.Text, Results: results, Count: len(results)}, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// MojeekProvider scrapes Mojeek's HTML results page
+type MojeekProvider struct {
+	client   *http.Client
+	endpoint providerEndpoint
+	scrapers *scraperSet
+	logger   *log.Logger
+}
+
+func NewMojeekProvider(logger *log.Logger, scrapers *scraperSet) *MojeekProvider {
+	endpoint := endpointFromEnv("mojeek", providerEndpoint{
+		URL:     "https://www.mojeek.com/search",
+		Timeout: 30 * time.Second,
+	})
+	return &MojeekProvider{client: endpoint.client(), endpoint: endpoint, scrapers: scrapers, logger: logger}
+}
+
+func (p *MojeekProvider) Name() string { return "mojeek" }
+
+func (p *MojeekProvider) Capabilities() ProviderCapabilities {
+	return ProviderCapabilities{Scraped: true, Snippets: true, MaxResults: 10}
+}
+
+func (p *MojeekProvider) Search(ctx context.Context, q Query) (*SearchResponse, error) {
+	req, err := p.endpoint.newRequest(ctx, url.Values{"q": {q.Text}}, setBrowserHeaders)
+	if err != nil {
+		return nil, newProviderError(p.Name(), ErrOther, fmt.Errorf("failed to create request: %w", err))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, requestError(p.Name(), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(p.Name(), resp)
+	}
+
+	var reader io.Reader = resp.Body
+	if os.Getenv("SEARCH_DEBUG") == "1" {
+		// Buffer the body so a small preview can go to stderr for debugging selectors
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, requestError(p.Name(), err)
+		}
+		preview := body
+		if len(preview) > 4096 {
+			preview = preview[:4096]
+		}
+		p.logger.Printf("[DEBUG] Mojeek HTML preview: %q", preview)
+		reader = bytes.NewReader(body)
+	}
+
+	results, err := p.parse(reader)
+	if err != nil {
+		return nil, err
+	}
+	results = q.limit(results)
+	return &SearchResponse{Query: q.Text, Results: results, Count: len(results)}, nil
+}
+
+// parse extracts the results from a Mojeek results page using the mojeek
+// scraper rules
+func (p *MojeekProvider) parse(r io.Reader) ([]SearchResult, error) {
+	return p.scrapers.parse(p.Name(), p.endpoint.base(), r)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"testing"
+)
+
+// stubProvider is a SearchProvider that returns canned results
+type stubProvider struct {
+	name    string
+	results []SearchResult
+	err     error
+	calls   int
+	last    Query
+}
+
+func (p *stubProvider) Name() string { return p.name }
+
+func (p *stubProvider) Capabilities() ProviderCapabilities { return ProviderCapabilities{} }
+
+func (p *stubProvider) Search(ctx context.Context, q Query) (*SearchResponse, error) {
+	p.calls++
+	p.last = q
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &SearchResponse{Query: q.Text, Results: p.results, Count: len(p.results)}, nil
+}
+
+func newTestServer(providers ...SearchProvider) *WebSearchServer {
+	server := NewWebSearchServer()
+	server.logger = log.New(os.Stderr, "[TEST] ", 0)
+	server.providers = NewProviderRegistry()
+	// Tests that exercise retries or caching set their own
+	server.retry = retryPolicy{}
+	server.cache = newResultCache(0, 0)
+	for _, p := range providers {
+		if err := server.providers.Register(p); err != nil {
+			panic(err)
+		}
+	}
+	return server
+}
+
+func TestProviderRegistry_OrderAndAliases(t *testing.T) {
+	registry, err := newDefaultRegistry(log.New(os.Stderr, "", 0), builtinScrapers(), "wiki, ddg")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	enabled := registry.Enabled()
+	if len(enabled) != 2 {
+		t.Fatalf("Expected 2 enabled providers, got %d", len(enabled))
+	}
+	if enabled[0].Name() != "wikipedia" || enabled[1].Name() != "duckduckgo" {
+		t.Errorf("Expected [wikipedia duckduckgo], got [%s %s]", enabled[0].Name(), enabled[1].Name())
+	}
+
+	if p, ok := registry.Get("DDG"); !ok || p.Name() != "duckduckgo" {
+		t.Error("Expected alias 'ddg' to resolve to duckduckgo")
+	}
+
+	if err := registry.SetEnabled("mojeek", true); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if n := len(registry.Enabled()); n != 3 {
+		t.Errorf("Expected 3 enabled providers after re-enabling mojeek, got %d", n)
+	}
+
+	if _, err := newDefaultRegistry(log.New(os.Stderr, "", 0), builtinScrapers(), "bing"); err == nil {
+		t.Error("Expected error for unknown provider")
+	}
+}
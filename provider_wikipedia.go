@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WikipediaProvider queries the MediaWiki search API (no API key), which
+// makes it a reliable last resort when the scraped engines fail
+type WikipediaProvider struct {
+	client   *http.Client
+	endpoint providerEndpoint
+}
+
+// NewWikipediaProvider searches English Wikipedia by default; pointing
+// SEARCH_WIKIPEDIA_URL at another MediaWiki's api.php searches that wiki
+func NewWikipediaProvider() *WikipediaProvider {
+	endpoint := endpointFromEnv("wikipedia", providerEndpoint{
+		URL:     "https://en.wikipedia.org/w/api.php",
+		Timeout: 20 * time.Second,
+	})
+	return &WikipediaProvider{client: endpoint.client(), endpoint: endpoint}
+}
+
+func (p *WikipediaProvider) Name() string { return "wikipedia" }
+
+func (p *WikipediaProvider) Capabilities() ProviderCapabilities {
+	return ProviderCapabilities{Scraped: false, Snippets: true, MaxResults: 500}
+}
+
+func (p *WikipediaProvider) Search(ctx context.Context, q Query) (*SearchResponse, error) {
+	params := url.Values{
+		"action":   {"query"},
+		"list":     {"search"},
+		"meta":     {"siteinfo"},
+		"siprop":   {"general"},
+		"format":   {"json"},
+		"utf8":     {"1"},
+		"srsearch": {q.Text},
+		"srlimit":  {strconv.Itoa(q.MaxResults)},
+	}
+	req, err := p.endpoint.newRequest(ctx, params, func(req *http.Request) {
+		req.Header.Set("User-Agent", "websearch-mcp/"+version+" (+https://example.com) Go-http-client")
+	})
+	if err != nil {
+		return nil, newProviderError(p.Name(), ErrOther, fmt.Errorf("failed to create request: %w", err))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, requestError(p.Name(), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(p.Name(), resp)
+	}
+
+	results, err := p.parse(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	results = q.limit(results)
+	return &SearchResponse{Query: q.Text, Results: results, Count: len(results)}, nil
+}
+
+// parse converts a MediaWiki list=search API response into results
+func (p *WikipediaProvider) parse(r io.Reader) ([]SearchResult, error) {
+	var data struct {
+		Query struct {
+			General struct {
+				Server      string `json:"server"`
+				ArticlePath string `json:"articlepath"`
+			} `json:"general"`
+			Search []struct {
+				Title   string `json:"title"`
+				PageID  int    `json:"pageid"`
+				Snippet string `json:"snippet"`
+			} `json:"search"`
+		} `json:"query"`
+	}
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return nil, newProviderError(p.Name(), ErrParse, fmt.Errorf("failed to decode JSON: %w", err))
+	}
+
+	root, articlePath := p.articleRoot(data.Query.General.Server, data.Query.General.ArticlePath)
+	results := make([]SearchResult, 0, len(data.Query.Search))
+	for i, item := range data.Query.Search {
+		// Link to the title rather than ?curid= so results match the URLs
+		// other engines return for the same article
+		pageURL := articleURL(root, articlePath, item.Title)
+		// Strip HTML from snippet
+		desc := strings.ReplaceAll(item.Snippet, "<span class=\"searchmatch\">", "")
+		desc = strings.ReplaceAll(desc, "</span>", "")
+		desc = strings.ReplaceAll(desc, "<span class=\"searchalttitle\">", "")
+		desc = strings.ReplaceAll(desc, "</span>", "")
+		results = append(results, SearchResult{
+			Title:       item.Title,
+			URL:         pageURL,
+			Description: desc,
+			Rank:        i + 1,
+		})
+	}
+	return results, nil
+}
+
+// articleRoot returns the URL article paths resolve against and the wiki's
+// article path, from the siteinfo in the response. Without siteinfo it
+// falls back to index.php next to api.php, which every MediaWiki serves.
+func (p *WikipediaProvider) articleRoot(server, articlePath string) (*url.URL, string) {
+	endpoint, err := url.Parse(p.endpoint.URL)
+	if err != nil {
+		endpoint = &url.URL{}
+	}
+	if articlePath == "" || !strings.Contains(articlePath, "$1") {
+		return endpoint, "index.php?title=$1"
+	}
+	root := endpoint
+	if server != "" {
+		// server is often protocol-relative, e.g. //en.wikipedia.org
+		if u, err := url.Parse(server); err == nil {
+			root = endpoint.ResolveReference(u)
+		}
+	}
+	return root, articlePath
+}
+
+// articleURL fills title into a MediaWiki article path such as /wiki/$1 or
+// /index.php?title=$1 and resolves it against root
+func articleURL(root *url.URL, articlePath, title string) string {
+	name := strings.ReplaceAll(title, " ", "_")
+	ref := &url.URL{}
+	if path, query, ok := strings.Cut(articlePath, "?"); ok {
+		ref.Path = path
+		ref.RawQuery = strings.ReplaceAll(query, "$1", url.QueryEscape(name))
+	} else {
+		ref.Path = strings.ReplaceAll(path, "$1", name)
+	}
+	return root.ResolveReference(ref).String()
+}
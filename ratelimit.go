@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errThrottled means a search would have waited longer for our own rate
+// limit than the caller's deadline or the maximum queue wait allows
+var errThrottled = errors.New("local rate limit would delay the request too long")
+
+// Default limit for scraped providers, which ban aggressive clients
+const defaultScrapeRateLimit = "30/m:5"
+
+// tokenBucket is a token-bucket rate limiter. Callers queue by reserving
+// tokens ahead of time, so waits are handed out in arrival order.
+type tokenBucket struct {
+	rate  float64 // tokens per second
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// reserve takes a token, going into debt if none is left, and returns how
+// long the caller must wait before using it
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// refund returns a reserved token that will not be used
+func (b *tokenBucket) refund() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens++
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+// wait blocks until a token is available. It gives up immediately with
+// errThrottled when the wait would be longer than maxWait or outlast ctx's
+// deadline, so the caller can move on to another provider instead. A maxWait
+// of 0 leaves only the deadline.
+func (b *tokenBucket) wait(ctx context.Context, maxWait time.Duration) error {
+	delay := b.reserve()
+	if delay == 0 {
+		return nil
+	}
+	tooLong := maxWait > 0 && delay > maxWait
+	if deadline, ok := ctx.Deadline(); tooLong || ok && time.Now().Add(delay).After(deadline) {
+		b.refund()
+		return errThrottled
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		b.refund()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// parseRateLimit parses "N/s", "N/m" or "N/h", optionally followed by
+// ":burst". The burst defaults to 1.
+func parseRateLimit(spec string) (float64, int, error) {
+	spec = strings.TrimSpace(spec)
+	burst := 1
+	if i := strings.Index(spec, ":"); i >= 0 {
+		b, err := strconv.Atoi(strings.TrimSpace(spec[i+1:]))
+		if err != nil || b <= 0 {
+			return 0, 0, fmt.Errorf("invalid burst in rate limit %q", spec)
+		}
+		burst = b
+		spec = spec[:i]
+	}
+
+	count, unit, ok := strings.Cut(spec, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid rate limit %q: expected e.g. 30/m", spec)
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(count), 64)
+	if err != nil || n <= 0 {
+		return 0, 0, fmt.Errorf("invalid rate limit %q: expected a positive count", spec)
+	}
+	var per time.Duration
+	switch strings.TrimSpace(unit) {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		return 0, 0, fmt.Errorf("invalid rate limit %q: unit must be s, m or h", spec)
+	}
+	return n / per.Seconds(), burst, nil
+}
+
+// newRateLimiters builds a bucket per provider. Scraped providers get
+// defaultScrapeRateLimit; spec is a comma-separated list of provider=limit
+// overrides, where a limit of "off" removes the provider's limit.
+func newRateLimiters(registry *ProviderRegistry, spec string) (map[string]*tokenBucket, error) {
+	limits := make(map[string]string)
+	for _, name := range registry.Names() {
+		if p, _ := registry.Get(name); p.Capabilities().Scraped {
+			limits[name] = defaultScrapeRateLimit
+		}
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		name, limit, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rate limit entry %q: expected provider=limit", entry)
+		}
+		p, ok := registry.Get(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("unknown provider %q", strings.TrimSpace(name))
+		}
+		limits[p.Name()] = strings.TrimSpace(limit)
+	}
+
+	buckets := make(map[string]*tokenBucket)
+	for name, limit := range limits {
+		if limit == "off" {
+			continue
+		}
+		rate, burst, err := parseRateLimit(limit)
+		if err != nil {
+			return nil, err
+		}
+		buckets[name] = newTokenBucket(rate, burst)
+	}
+	return buckets, nil
+}
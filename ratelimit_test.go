@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		spec  string
+		rate  float64
+		burst int
+	}{
+		{"2/s", 2, 1},
+		{"30/m:5", 0.5, 5},
+		{"360/h:2", 0.1, 2},
+	}
+	for _, tt := range tests {
+		rate, burst, err := parseRateLimit(tt.spec)
+		if err != nil || rate != tt.rate || burst != tt.burst {
+			t.Errorf("parseRateLimit(%q) = %v, %d, %v; want %v, %d", tt.spec, rate, burst, err, tt.rate, tt.burst)
+		}
+	}
+	for _, bad := range []string{"", "5", "0/s", "5/d", "5/s:0"} {
+		if _, _, err := parseRateLimit(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestNewRateLimiters(t *testing.T) {
+	registry, _ := newDefaultRegistry(log.New(os.Stderr, "", 0), builtinScrapers(), "")
+	limits, err := newRateLimiters(registry, "ddg=1/s:2, mojeek=off")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if b := limits["duckduckgo"]; b == nil || b.rate != 1 || b.burst != 2 {
+		t.Errorf("Expected the ddg alias to configure duckduckgo, got %+v", b)
+	}
+	if _, ok := limits["mojeek"]; ok {
+		t.Error("Expected mojeek's limit to be switched off")
+	}
+	if _, ok := limits["wikipedia"]; ok {
+		t.Error("Expected no default limit for the Wikipedia API")
+	}
+}
+
+func TestTokenBucket_Wait(t *testing.T) {
+	b := newTokenBucket(20, 1)
+	if err := b.wait(context.Background(), 0); err != nil {
+		t.Fatalf("Expected the burst token to be free, got %v", err)
+	}
+
+	start := time.Now()
+	if err := b.wait(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("Expected to wait for a token, waited %s", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if err := b.wait(ctx, 0); !errors.Is(err, errThrottled) {
+		t.Errorf("Expected errThrottled when the wait outlasts the deadline, got %v", err)
+	}
+	if err := b.wait(context.Background(), 5*time.Millisecond); !errors.Is(err, errThrottled) {
+		t.Errorf("Expected errThrottled when the wait exceeds maxWait, got %v", err)
+	}
+}
+
+func TestWebSearchServer_ThrottledProviderFallsBack(t *testing.T) {
+	limited := &stubProvider{name: "limited", results: []SearchResult{{Title: "A", URL: "https://a.example", Rank: 1}}}
+	other := &stubProvider{name: "other", results: []SearchResult{{Title: "B", URL: "https://b.example", Rank: 1}}}
+	server := newTestServer(limited, other)
+	server.limits = map[string]*tokenBucket{"limited": newTokenBucket(1.0/60, 1)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for i, want := range []string{"limited", "other"} {
+		res, err := server.performWebSearch(ctx, "q", 5)
+		if err != nil || res.Providers[0] != want {
+			t.Errorf("Search %d: expected results from %s, got %v %v", i+1, want, res, err)
+		}
+	}
+	if report := server.health.snapshot([]string{"limited"})["limited"]; report.ConsecutiveFailures != 0 {
+		t.Errorf("Expected throttling not to count against health, got %+v", report)
+	}
+}
+
+func TestWebSearchServer_ThrottledFallsBackWithoutDeadline(t *testing.T) {
+	limited := &stubProvider{name: "limited", results: []SearchResult{{Title: "A", URL: "https://a.example", Rank: 1}}}
+	other := &stubProvider{name: "other", results: []SearchResult{{Title: "B", URL: "https://b.example", Rank: 1}}}
+	server := newTestServer(limited, other)
+	server.limits = map[string]*tokenBucket{"limited": newTokenBucket(1.0/60, 1)}
+	server.maxQueueWait = 50 * time.Millisecond
+
+	start := time.Now()
+	for i, want := range []string{"limited", "other"} {
+		res, err := server.performWebSearch(context.Background(), fmt.Sprintf("q%d", i), 5)
+		if err != nil || res.Providers[0] != want {
+			t.Errorf("Search %d: expected results from %s, got %v %v", i+1, want, res, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the second search to move on instead of queueing, took %s", elapsed)
+	}
+}
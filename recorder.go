@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fixture is one recorded provider HTTP exchange
+type fixture struct {
+	Request  fixtureRequest  `json:"request"`
+	Response fixtureResponse `json:"response"`
+}
+
+type fixtureRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+type fixtureResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+}
+
+// fixtureName maps a request to its fixture file. Requests are matched on
+// method and URL only, since headers such as the User-Agent may change
+// between recording and replay.
+func fixtureName(r *http.Request) string {
+	sum := sha256.Sum256([]byte(r.Method + " " + r.URL.String()))
+	return r.URL.Hostname() + "-" + hex.EncodeToString(sum[:8]) + ".json"
+}
+
+// sensitiveHeaders are never written to fixtures, which are meant to be
+// committed alongside tests
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// recordingTransport passes requests through to next and saves every
+// exchange as a fixture in dir, with the values of the redact headers
+// replaced
+type recordingTransport struct {
+	dir    string
+	next   http.RoundTripper
+	redact []string
+}
+
+// newRecordingTransport redacts sensitiveHeaders and every header configured
+// through a SEARCH_<NAME>_HEADERS variable, since those typically carry API
+// keys
+func newRecordingTransport(dir string, next http.RoundTripper) *recordingTransport {
+	redact := append([]string(nil), sensitiveHeaders...)
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(key, "SEARCH_") || !strings.HasSuffix(key, "_HEADERS") {
+			continue
+		}
+		header, err := parseHeaders(value)
+		if err != nil {
+			continue
+		}
+		for name := range header {
+			redact = append(redact, name)
+		}
+	}
+	return &recordingTransport{dir: dir, next: next, redact: redact}
+}
+
+// redacted returns a copy of h with the values of the redact headers replaced
+func (t *recordingTransport) redacted(h http.Header) http.Header {
+	h = h.Clone()
+	for _, name := range t.redact {
+		if _, ok := h[http.CanonicalHeaderKey(name)]; ok {
+			h.Set(name, "REDACTED")
+		}
+	}
+	return h
+}
+
+func (t *recordingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if r.Body != nil {
+		var err error
+		if reqBody, err = io.ReadAll(r.Body); err != nil {
+			return nil, err
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	resp, err := t.next.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	f := fixture{
+		Request: fixtureRequest{
+			Method: r.Method,
+			URL:    r.URL.String(),
+			Header: t.redacted(r.Header),
+			Body:   string(reqBody),
+		},
+		Response: fixtureResponse{
+			StatusCode: resp.StatusCode,
+			Header:     t.redacted(resp.Header),
+			Body:       string(body),
+		},
+	}
+	if err := saveFixture(filepath.Join(t.dir, fixtureName(r)), f); err != nil {
+		return nil, fmt.Errorf("failed to record fixture: %w", err)
+	}
+	return resp, nil
+}
+
+func saveFixture(path string, f fixture) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// replayTransport answers requests from fixtures in dir and never touches
+// the network. A request without a fixture fails.
+type replayTransport struct {
+	dir string
+}
+
+func (t *replayTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Body != nil {
+		r.Body.Close()
+	}
+	data, err := os.ReadFile(filepath.Join(t.dir, fixtureName(r)))
+	if err != nil {
+		return nil, fmt.Errorf("no recorded fixture for %s %s: %w", r.Method, r.URL, err)
+	}
+	var f fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid fixture for %s %s: %w", r.Method, r.URL, err)
+	}
+
+	header := f.Response.Header
+	if header == nil {
+		header = make(http.Header)
+	}
+	// The recorded body is already decoded
+	header.Del("Content-Encoding")
+	header.Del("Content-Length")
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", f.Response.StatusCode, http.StatusText(f.Response.StatusCode)),
+		StatusCode:    f.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(f.Response.Body)),
+		ContentLength: int64(len(f.Response.Body)),
+		Request:       r,
+	}, nil
+}
+
+// providerTransport returns the transport provider HTTP clients use:
+// fixtures only when SEARCH_REPLAY_DIR is set, the network with every
+// exchange saved when SEARCH_RECORD_DIR is set, and plain network otherwise
+func providerTransport() http.RoundTripper {
+	if dir := os.Getenv("SEARCH_REPLAY_DIR"); dir != "" {
+		return &replayTransport{dir: dir}
+	}
+	if dir := os.Getenv("SEARCH_RECORD_DIR"); dir != "" {
+		return newRecordingTransport(dir, http.DefaultTransport)
+	}
+	return http.DefaultTransport
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, "<p>"+r.URL.Query().Get("q")+"</p>")
+	}))
+	defer upstream.Close()
+	dir := t.TempDir()
+
+	record := &http.Client{Transport: &recordingTransport{dir: dir, next: http.DefaultTransport}}
+	resp, err := record.Get(upstream.URL + "/search?q=golang")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(recorded) != "<p>golang</p>" {
+		t.Fatalf("Expected the live body while recording, got %q", recorded)
+	}
+
+	// Replay works with the upstream gone
+	upstream.Close()
+	replay := &http.Client{Transport: &replayTransport{dir: dir}}
+	resp, err = replay.Get(upstream.URL + "/search?q=golang")
+	if err != nil {
+		t.Fatalf("Expected the recorded exchange, got %v", err)
+	}
+	replayed, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(replayed) != string(recorded) || resp.Header.Get("Content-Type") != "text/html" {
+		t.Errorf("Expected the recorded response, got %q %v", replayed, resp.Header)
+	}
+
+	if _, err := replay.Get(upstream.URL + "/search?q=other"); err == nil {
+		t.Error("Expected an error for a request without a fixture")
+	}
+}
+
+func TestWikipediaProvider_Replay(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SEARCH_REPLAY_DIR", dir)
+
+	// Record a fixture for the request the provider is about to make
+	var captured *http.Request
+	p := NewWikipediaProvider()
+	p.client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		captured = r
+		return cannedResponse(http.StatusOK, "{}")(r)
+	})
+	p.Search(context.Background(), Query{Text: "golang", MaxResults: 2})
+	err := saveFixture(filepath.Join(dir, fixtureName(captured)), fixture{
+		Request:  fixtureRequest{Method: captured.Method, URL: captured.URL.String()},
+		Response: fixtureResponse{StatusCode: http.StatusOK, Body: `{"query":{"general":{"server":"//en.wikipedia.org","articlepath":"/wiki/$1"},"search":[{"title":"Go (programming language)","snippet":"Go is a language"}]}}`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := NewWikipediaProvider().Search(context.Background(), Query{Text: "golang", MaxResults: 2})
+	if err != nil {
+		t.Fatalf("Expected a replayed search, got %v", err)
+	}
+	if res.Count != 1 || res.Results[0].URL != "https://en.wikipedia.org/wiki/Go_%28programming_language%29" {
+		t.Errorf("Unexpected replayed results: %+v", res.Results)
+	}
+}
+
+func TestRecordingTransport_RedactsCredentials(t *testing.T) {
+	t.Setenv("SEARCH_EXAMPLE_HEADERS", "X-Api-Key: key-secret")
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "cookie-secret"})
+		io.WriteString(w, "ok")
+	}))
+	defer upstream.Close()
+	dir := t.TempDir()
+
+	req, _ := http.NewRequest(http.MethodGet, upstream.URL+"/search?q=golang", nil)
+	req.Header.Set("Authorization", "Bearer token-secret")
+	req.Header.Set("Cookie", "session=cookie-secret")
+	req.Header.Set("X-Api-Key", "key-secret")
+	req.Header.Set("User-Agent", "test-agent")
+
+	client := &http.Client{Transport: newRecordingTransport(dir, http.DefaultTransport)}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("Set-Cookie") == "" {
+		t.Error("Expected the live response to keep its headers")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, fixtureName(req)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Errorf("Expected credentials to be redacted from the fixture, got %s", data)
+	}
+	var f fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Authorization", "Cookie", "X-Api-Key"} {
+		if got := f.Request.Header.Get(name); got != "REDACTED" {
+			t.Errorf("Expected request header %s to be redacted, got %q", name, got)
+		}
+	}
+	if got := f.Response.Header.Get("Set-Cookie"); got != "REDACTED" {
+		t.Errorf("Expected Set-Cookie to be redacted, got %q", got)
+	}
+	if got := f.Request.Header.Get("User-Agent"); got != "test-agent" {
+		t.Errorf("Expected other headers to be kept, got User-Agent %q", got)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retryPolicy retries transient provider failures with jittered exponential
+// backoff
+type retryPolicy struct {
+	// retries is how many times a failed call is repeated; 0 disables retries
+	retries int
+	base    time.Duration
+	max     time.Duration
+}
+
+func retryPolicyFromEnv() retryPolicy {
+	return retryPolicy{
+		retries: envCount("SEARCH_RETRIES", 1),
+		base:    envDuration("SEARCH_RETRY_BASE", 250*time.Millisecond),
+		max:     envDuration("SEARCH_RETRY_MAX", 5*time.Second),
+	}
+}
+
+// retryable reports whether err is worth another attempt: a network error,
+// a 502/503/504, or rate limiting
+func retryable(err error) bool {
+	var pe *ProviderError
+	if !errors.As(err, &pe) {
+		return false
+	}
+	switch pe.Kind {
+	case ErrNetwork, ErrRateLimited:
+		return true
+	case ErrUpstream:
+		switch pe.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns the wait before retry number attempt (starting at 1). A
+// Retry-After from the provider wins over the computed backoff.
+func (rp retryPolicy) delay(attempt int, err error) time.Duration {
+	var pe *ProviderError
+	if errors.As(err, &pe) && pe.RetryAfter > 0 {
+		return pe.RetryAfter
+	}
+	d := rp.base
+	for i := 1; i < attempt && d < rp.max; i++ {
+		d *= 2
+	}
+	if d > rp.max {
+		d = rp.max
+	}
+	// Full jitter over the upper half keeps concurrent retries apart
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// do calls search until it succeeds, fails for good, or runs out of retries.
+// Retries that would wait past max or past ctx's deadline are not attempted.
+func (rp retryPolicy) do(ctx context.Context, search func() (*SearchResponse, error)) (*SearchResponse, error) {
+	res, err := search()
+	for attempt := 1; err != nil && attempt <= rp.retries && retryable(err); attempt++ {
+		wait := rp.delay(attempt, err)
+		if wait > rp.max {
+			return nil, err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return nil, err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			// The caller gave up; the provider's last error is beside the point
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		res, err = search()
+	}
+	return res, err
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP
+// date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// searchHedged works like searchFallback but does not wait for a slow
+// provider: when one has not answered within delay, the next provider is
+// started alongside it and the first non-empty answer wins. A provider that
+// fails or comes back empty starts the next one right away.
+func (s *WebSearchServer) searchHedged(ctx context.Context, providers []SearchProvider, q Query, delay time.Duration) (*SearchResponse, error) {
+	if len(providers) == 0 {
+		return nil, errors.New("no search providers enabled")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	// Stops the losers once there is an answer
+	defer cancel()
+
+	type outcome struct {
+		res *SearchResponse
+		err error
+	}
+	outcomes := make(chan outcome, len(providers))
+	next := 0
+	start := func() {
+		p := providers[next]
+		next++
+		go func() {
+			res, err := s.searchProvider(ctx, p, q)
+			outcomes <- outcome{res: res, err: err}
+		}()
+	}
+
+	start()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var (
+		empty    *SearchResponse
+		failures []error
+	)
+	for pending := 1; pending > 0; {
+		select {
+		case <-timer.C:
+			if next < len(providers) {
+				s.logger.Printf("No answer after %s; hedging with %s", delay, providers[next].Name())
+				start()
+				pending++
+				timer.Reset(delay)
+			}
+			continue
+		case o := <-outcomes:
+			pending--
+			switch {
+			case o.err == nil && len(o.res.Results) > 0:
+				o.res.degraded = len(failures) > 0
+				return o.res, nil
+			case o.err != nil:
+				s.logger.Printf("Provider %v", o.err)
+				failures = append(failures, o.err)
+			default:
+				empty = o.res
+			}
+		}
+		if next < len(providers) {
+			start()
+			pending++
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(delay)
+		}
+	}
+
+	if empty != nil {
+		empty.degraded = len(failures) > 0
+		return empty, nil
+	}
+	return nil, errors.Join(failures...)
+}
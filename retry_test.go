@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// flakyProvider fails with err for its first failures calls
+type flakyProvider struct {
+	stubProvider
+	failures int
+}
+
+func (p *flakyProvider) Search(ctx context.Context, q Query) (*SearchResponse, error) {
+	if p.calls < p.failures {
+		p.calls++
+		return nil, p.err
+	}
+	p.err = nil
+	return p.stubProvider.Search(ctx, q)
+}
+
+func TestRetryPolicy_RetriesTransientFailures(t *testing.T) {
+	unavailable := &ProviderError{Provider: "flaky", Kind: ErrUpstream, StatusCode: http.StatusServiceUnavailable, Err: errors.New("503")}
+	flaky := &flakyProvider{
+		stubProvider: stubProvider{name: "flaky", err: unavailable, results: []SearchResult{{Title: "Hit", URL: "https://example.com", Rank: 1}}},
+		failures:     2,
+	}
+	server := newTestServer(flaky)
+	server.retry = retryPolicy{retries: 2, base: time.Millisecond, max: 10 * time.Millisecond}
+
+	res, err := server.searchProvider(context.Background(), flaky, Query{Text: "q", MaxResults: 5})
+	if err != nil || res.Count != 1 {
+		t.Fatalf("Expected success after retries, got %v %v", res, err)
+	}
+	if flaky.calls != 3 {
+		t.Errorf("Expected 3 calls, got %d", flaky.calls)
+	}
+
+	parse := &stubProvider{name: "parse", err: newProviderError("parse", ErrParse, errors.New("layout changed"))}
+	if _, err := server.searchProvider(context.Background(), parse, Query{Text: "q"}); err == nil || parse.calls != 1 {
+		t.Errorf("Expected parse errors not to be retried, got %d calls", parse.calls)
+	}
+}
+
+func TestRetryPolicy_CancelDuringBackoff(t *testing.T) {
+	unavailable := &ProviderError{Provider: "flaky", Kind: ErrUpstream, StatusCode: http.StatusServiceUnavailable, Err: errors.New("503")}
+	flaky := &flakyProvider{stubProvider: stubProvider{name: "flaky", err: unavailable}, failures: 5}
+	server := newTestServer(flaky)
+	server.retry = retryPolicy{retries: 3, base: time.Second, max: time.Second}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	_, err := server.searchProvider(ctx, flaky, Query{Text: "q", MaxResults: 5})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if report := server.health.snapshot([]string{"flaky"})["flaky"]; report.Calls != 0 || report.ConsecutiveFailures != 0 {
+		t.Errorf("Expected a cancelled search not to count against health, got %+v", report)
+	}
+	if counts := server.stats.GetStats()["provider_errors"].(map[ErrorKind]int64); len(counts) != 0 {
+		t.Errorf("Expected no provider errors for a cancelled search, got %v", counts)
+	}
+}
+
+func TestRetryPolicy_RetryAfter(t *testing.T) {
+	rp := retryPolicy{retries: 1, base: time.Millisecond, max: time.Second}
+	limited := &ProviderError{Kind: ErrRateLimited, RetryAfter: 2 * time.Second, Err: errors.New("429")}
+
+	calls := 0
+	_, err := rp.do(context.Background(), func() (*SearchResponse, error) {
+		calls++
+		return nil, limited
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("Expected no retry when Retry-After exceeds the maximum wait, got %d calls", calls)
+	}
+
+	if got := parseRetryAfter("3"); got != 3*time.Second {
+		t.Errorf("Expected 3s from Retry-After seconds, got %s", got)
+	}
+	if got := parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)); got < 58*time.Second || got > time.Minute {
+		t.Errorf("Expected about a minute from Retry-After date, got %s", got)
+	}
+}
+
+func TestWebSearchServer_SearchHedged(t *testing.T) {
+	slow := &blockingProvider{name: "slow"}
+	fast := &stubProvider{name: "fast", results: []SearchResult{{Title: "Hit", URL: "https://example.com", Rank: 1}}}
+	server := newTestServer(slow, fast)
+
+	start := time.Now()
+	res, err := server.searchHedged(context.Background(), server.providers.Enabled(), Query{Text: "q", MaxResults: 5}, 20*time.Millisecond)
+	if err != nil || res.Providers[0] != "fast" {
+		t.Fatalf("Expected the hedged provider to answer, got %v %v", res, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the hedge to cut latency, took %s", elapsed)
+	}
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+)
+
+// defaultScrapersJSON holds the built-in scraping rules for every HTML engine
+//
+//go:embed scrapers.json
+var defaultScrapersJSON []byte
+
+// engineNamePattern keeps engine names usable in SEARCH_<NAME>_* variables
+var engineNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+type scraperFile struct {
+	Engines map[string]*scraperRules `json:"engines"`
+}
+
+// scraperRules describe how to pull results out of one engine's HTML results
+// page. Selector lists are tried in order and the first one that matches
+// wins.
+type scraperRules struct {
+	// URL, QueryParam, Params and MaxResults define the request for engines
+	// that exist only in configuration; built-in providers ignore them
+	URL        string            `json:"url,omitempty"`
+	QueryParam string            `json:"query_param,omitempty"`
+	Params     map[string]string `json:"params,omitempty"`
+	MaxResults int               `json:"max_results,omitempty"`
+
+	// Container selects one element per result
+	Container string `json:"container"`
+	// Exclude skips containers matching any of these selectors, e.g. ads
+	Exclude []string `json:"exclude,omitempty"`
+	Title   []string `json:"title"`
+	// Link selects the element whose href is the result URL; the title
+	// element is used when empty
+	Link    []string `json:"link,omitempty"`
+	Snippet []string `json:"snippet,omitempty"`
+	// ExcludeLinks are regular expressions; results whose raw or resolved
+	// link matches any of them are dropped
+	ExcludeLinks []string       `json:"exclude_links,omitempty"`
+	Redirects    []redirectRule `json:"redirects,omitempty"`
+	// NoResults recognises the engine's "nothing found" page. An empty page
+	// without it means the selectors no longer match and is a parse error.
+	NoResults pageMarker `json:"no_results"`
+	Blocked   pageMarker `json:"blocked"`
+
+	excludeLinks []*regexp.Regexp
+	redirects    []*regexp.Regexp
+}
+
+// redirectRule unwraps tracking links: a link matching Match is replaced by
+// the value of its Param query parameter
+type redirectRule struct {
+	Match string `json:"match"`
+	Param string `json:"param"`
+}
+
+// compile validates the rules and prepares their regular expressions
+func (r *scraperRules) compile() error {
+	if r.Container == "" || len(r.Title) == 0 {
+		return errors.New("container and title are required")
+	}
+	if r.URL != "" {
+		if u, err := url.Parse(r.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid url %q: expected an absolute URL", r.URL)
+		}
+	}
+
+	selectors := []string{r.Container}
+	selectors = append(selectors, r.Exclude...)
+	selectors = append(selectors, r.Title...)
+	selectors = append(selectors, r.Link...)
+	selectors = append(selectors, r.Snippet...)
+	selectors = append(selectors, r.NoResults.Selectors...)
+	selectors = append(selectors, r.Blocked.Selectors...)
+	for _, sel := range selectors {
+		// goquery silently matches nothing for a bad selector, so check here
+		if _, err := cascadia.ParseGroup(sel); err != nil {
+			return fmt.Errorf("invalid selector %q: %w", sel, err)
+		}
+	}
+
+	r.excludeLinks = r.excludeLinks[:0]
+	for _, pattern := range r.ExcludeLinks {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid exclude_links pattern: %w", err)
+		}
+		r.excludeLinks = append(r.excludeLinks, re)
+	}
+	r.redirects = r.redirects[:0]
+	for _, rule := range r.Redirects {
+		if rule.Param == "" {
+			return fmt.Errorf("redirect %q has no param", rule.Match)
+		}
+		re, err := regexp.Compile(rule.Match)
+		if err != nil {
+			return fmt.Errorf("invalid redirect pattern: %w", err)
+		}
+		r.redirects = append(r.redirects, re)
+	}
+	return nil
+}
+
+// parse extracts results from a results page. Relative links resolve
+// against base, the scheme and host the page was fetched from.
+func (r *scraperRules) parse(provider string, base *url.URL, body io.Reader) ([]SearchResult, error) {
+	doc, err := goquery.NewDocumentFromReader(body)
+	if err != nil {
+		return nil, newProviderError(provider, ErrParse, fmt.Errorf("failed to parse HTML: %w", err))
+	}
+
+	var results []SearchResult
+	doc.Find(r.Container).Each(func(i int, sel *goquery.Selection) {
+		for _, exclude := range r.Exclude {
+			if sel.Is(exclude) {
+				return
+			}
+		}
+		titleSel := firstMatch(sel, r.Title)
+		if titleSel == nil {
+			return
+		}
+		title := strings.TrimSpace(titleSel.Text())
+		linkSel := titleSel
+		if len(r.Link) > 0 {
+			if linkSel = firstMatch(sel, r.Link); linkSel == nil {
+				return
+			}
+		}
+		href, exists := linkSel.Attr("href")
+		if !exists || title == "" {
+			return
+		}
+		link, ok := r.resolve(base, href)
+		if !ok {
+			return
+		}
+
+		var desc string
+		for _, selector := range r.Snippet {
+			if desc = strings.TrimSpace(sel.Find(selector).First().Text()); desc != "" {
+				break
+			}
+		}
+
+		results = append(results, SearchResult{
+			Title:       title,
+			URL:         link,
+			Description: desc,
+			Rank:        len(results) + 1,
+		})
+	})
+
+	if len(results) == 0 {
+		if r.Blocked.matches(doc) {
+			return nil, newProviderError(provider, ErrBlocked, errBlockPage)
+		}
+		if !r.NoResults.matches(doc) {
+			return nil, newProviderError(provider, ErrParse, errors.New("no results matched the result selectors; the page layout may have changed"))
+		}
+	}
+	return results, nil
+}
+
+// resolve turns href into an absolute http(s) result URL, unwrapping
+// redirect links. ok is false when the link should be dropped.
+func (r *scraperRules) resolve(base *url.URL, href string) (link string, ok bool) {
+	if r.excluded(href) {
+		return "", false
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return "", false
+	}
+	abs := ref
+	link = href
+	if !ref.IsAbs() {
+		abs = base.ResolveReference(ref)
+		link = abs.String()
+	}
+	for i, re := range r.redirects {
+		if !re.MatchString(href) {
+			continue
+		}
+		if target := abs.Query().Get(r.Redirects[i].Param); target != "" {
+			// Some engines encode the target twice
+			if decoded, err := url.QueryUnescape(target); err == nil {
+				target = decoded
+			}
+			link = target
+		}
+		break
+	}
+
+	if !strings.HasPrefix(link, "http://") && !strings.HasPrefix(link, "https://") {
+		return "", false
+	}
+	return link, !r.excluded(link)
+}
+
+func (r *scraperRules) excluded(link string) bool {
+	for _, re := range r.excludeLinks {
+		if re.MatchString(link) {
+			return true
+		}
+	}
+	return false
+}
+
+// firstMatch returns the first element matched by the first selector that
+// matches anything under sel, or nil
+func firstMatch(sel *goquery.Selection, selectors []string) *goquery.Selection {
+	for _, selector := range selectors {
+		if found := sel.Find(selector).First(); found.Length() > 0 {
+			return found
+		}
+	}
+	return nil
+}
+
+// parseScraperFile reads and validates a rules file
+func parseScraperFile(data []byte) (map[string]*scraperRules, error) {
+	var file scraperFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	for name, rules := range file.Engines {
+		if !engineNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid engine name %q: use lowercase letters, digits and underscores", name)
+		}
+		if rules == nil {
+			return nil, fmt.Errorf("engine %s: no rules", name)
+		}
+		if err := rules.compile(); err != nil {
+			return nil, fmt.Errorf("engine %s: %w", name, err)
+		}
+	}
+	return file.Engines, nil
+}
+
+// scraperSetRecheck is how often a scraper rules file is checked for changes
+const scraperSetRecheck = 2 * time.Second
+
+// scraperSet holds the scraping rules of every HTML engine: the embedded
+// defaults, with engines replaced or added by a rules file. The file is
+// checked for changes every few seconds, so fixed selectors take effect
+// without a restart. A file that fails to load is logged and the previous
+// rules stay in use.
+type scraperSet struct {
+	path    string
+	logger  *log.Logger
+	recheck time.Duration
+
+	mu       sync.Mutex
+	defaults map[string]*scraperRules
+	rules    map[string]*scraperRules
+	checked  time.Time
+	loaded   bool
+	missing  bool
+	modTime  time.Time
+	size     int64
+}
+
+// newScraperSet loads the embedded rules and, when path is set, the rules
+// file that overrides them
+func newScraperSet(path string, logger *log.Logger) *scraperSet {
+	defaults, err := parseScraperFile(defaultScrapersJSON)
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded scraper rules: %v", err))
+	}
+	s := &scraperSet{
+		path:     path,
+		logger:   logger,
+		recheck:  scraperSetRecheck,
+		defaults: defaults,
+		rules:    defaults,
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh(time.Now())
+	return s
+}
+
+// get returns the current rules for an engine
+func (s *scraperSet) get(name string) (*scraperRules, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now := time.Now(); now.Sub(s.checked) >= s.recheck {
+		s.refresh(now)
+	}
+	rules, ok := s.rules[name]
+	return rules, ok
+}
+
+// engines lists the engines that are defined only by configuration
+func (s *scraperSet) engines() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	for name, rules := range s.rules {
+		if rules.URL != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parse parses a results page with the current rules for provider
+func (s *scraperSet) parse(provider string, base *url.URL, body io.Reader) ([]SearchResult, error) {
+	rules, ok := s.get(provider)
+	if !ok {
+		return nil, newProviderError(provider, ErrOther, fmt.Errorf("no scraper rules for %s", provider))
+	}
+	return rules.parse(provider, base, body)
+}
+
+// refresh reloads the rules file if it changed since it was last loaded. The
+// caller holds s.mu.
+func (s *scraperSet) refresh(now time.Time) {
+	s.checked = now
+	if s.path == "" {
+		return
+	}
+	st, err := os.Stat(s.path)
+	if err != nil {
+		if !s.missing {
+			s.logger.Printf("Ignoring scraper rules file: %v", err)
+			s.missing = true
+		}
+		return
+	}
+	s.missing = false
+	if st.ModTime().Equal(s.modTime) && st.Size() == s.size {
+		return
+	}
+	reloading := s.loaded
+	s.modTime, s.size = st.ModTime(), st.Size()
+
+	data, err := os.ReadFile(s.path)
+	if err == nil {
+		var overrides map[string]*scraperRules
+		if overrides, err = parseScraperFile(data); err == nil {
+			rules := make(map[string]*scraperRules, len(s.defaults)+len(overrides))
+			for name, r := range s.defaults {
+				rules[name] = r
+			}
+			for name, r := range overrides {
+				rules[name] = r
+			}
+			s.rules, s.loaded = rules, true
+			if reloading {
+				s.logger.Printf("Reloaded scraper rules from %s", s.path)
+			}
+			return
+		}
+	}
+	s.logger.Printf("Ignoring invalid scraper rules in %s: %v", s.path, err)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// builtinScrapers returns the embedded scraper rules
+func builtinScrapers() *scraperSet {
+	return newScraperSet("", log.New(io.Discard, "", 0))
+}
+
+func parseDuckDuckGoFixture(t *testing.T, scrapers *scraperSet) []SearchResult {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", "duckduckgo", "results.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	results, err := NewDuckDuckGoProvider(scrapers).parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return results
+}
+
+func TestScraperSet_OverrideAndReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scrapers.json")
+	write := func(data string) {
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`{"engines": {"duckduckgo": {
+		"container": ".result", "exclude": [".result--ad"], "title": ["a.result__a"],
+		"redirects": [{"match": "/l/\\?", "param": "uddg"}], "no_results": {"selectors": [".no-results"]}
+	}}}`)
+	scrapers := newScraperSet(path, log.New(io.Discard, "", 0))
+	// Check the file on every lookup rather than every few seconds
+	scrapers.recheck = 0
+
+	// The override replaces the whole engine: no snippets, and the fallback
+	// title selector is gone
+	results := parseDuckDuckGoFixture(t, scrapers)
+	if len(results) != 2 || results[0].Description != "" {
+		t.Fatalf("Expected the override rules, got %+v", results)
+	}
+
+	write(`{"engines": {"duckduckgo": {
+		"container": ".result", "exclude": [".result--ad"], "title": ["a.result__a"],
+		"exclude_links": ["wikipedia"],
+		"redirects": [{"match": "/l/\\?", "param": "uddg"}], "no_results": {"selectors": [".no-results"]}
+	}}}`)
+	if results := parseDuckDuckGoFixture(t, scrapers); len(results) != 1 || results[0].URL != "https://go.dev/" {
+		t.Fatalf("Expected the edited rules to be picked up, got %+v", results)
+	}
+
+	// A broken edit keeps the last good rules
+	write(`{"engines": {"duckduckgo": {"container": "div[", "title": ["a"]}}}`)
+	if results := parseDuckDuckGoFixture(t, scrapers); len(results) != 1 {
+		t.Fatalf("Expected the previous rules after a broken edit, got %+v", results)
+	}
+
+	if results := parseDuckDuckGoFixture(t, builtinScrapers()); len(results) != 3 {
+		t.Fatalf("Expected the built-in rules without an override file, got %+v", results)
+	}
+}
+
+func TestScraperSet_ConfigEngine(t *testing.T) {
+	var query, lang string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, lang = r.URL.Query().Get("query"), r.URL.Query().Get("lang")
+		if query == "nothing" {
+			io.WriteString(w, `<html><body><p>Nothing found.</p></body></html>`)
+			return
+		}
+		io.WriteString(w, `<html><body>
+<div class="hit"><h3><a href="/out?to=https%3A%2F%2Fgo.dev%2F">Go</a></h3><p>The Go language</p></div>
+<div class="hit sponsored"><h3><a href="https://ads.example.com/">Ad</a></h3></div>
+<div class="hit"><h3><a href="https://pkg.go.dev/">Packages</a></h3></div>
+</body></html>`)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "scrapers.json")
+	err := os.WriteFile(path, []byte(`{"engines": {"example": {
+		"url": "`+srv.URL+`/search", "query_param": "query", "params": {"lang": "en"}, "max_results": 20,
+		"container": "div.hit", "exclude": [".sponsored"], "title": ["h3 a"], "snippet": ["p"],
+		"redirects": [{"match": "^/out\\?", "param": "to"}],
+		"no_results": {"phrases": ["nothing found"]}
+	}}}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := log.New(os.Stderr, "", 0)
+	registry, err := newDefaultRegistry(logger, newScraperSet(path, logger), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, ok := registry.Get("example")
+	if !ok {
+		t.Fatalf("Expected the configured engine to be registered, got %v", registry.Names())
+	}
+	if caps := p.Capabilities(); !caps.Scraped || !caps.Snippets || caps.MaxResults != 20 {
+		t.Errorf("Unexpected capabilities: %+v", caps)
+	}
+
+	res, err := p.Search(context.Background(), Query{Text: "golang", MaxResults: 5})
+	if err != nil {
+		t.Fatalf("Expected results, got %v", err)
+	}
+	if query != "golang" || lang != "en" {
+		t.Errorf("Expected query=golang&lang=en, got query=%q lang=%q", query, lang)
+	}
+	want := []SearchResult{
+		{Title: "Go", URL: "https://go.dev/", Description: "The Go language", Rank: 1},
+		{Title: "Packages", URL: "https://pkg.go.dev/", Rank: 2},
+	}
+	if !reflect.DeepEqual(res.Results, want) {
+		t.Errorf("Expected %+v, got %+v", want, res.Results)
+	}
+
+	res, err = p.Search(context.Background(), Query{Text: "nothing", MaxResults: 5})
+	if err != nil || res.Count != 0 {
+		t.Errorf("Expected an empty result for the no-results page, got %v %v", res, err)
+	}
+}
+
+func TestParseScraperFile_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"bad JSON", `{"engines": `, "unexpected end"},
+		{"bad name", `{"engines": {"My-Engine": {"container": "div", "title": ["a"]}}}`, "invalid engine name"},
+		{"missing title", `{"engines": {"x": {"container": "div"}}}`, "container and title are required"},
+		{"bad selector", `{"engines": {"x": {"container": "div", "title": ["a["]}}}`, "invalid selector"},
+		{"bad pattern", `{"engines": {"x": {"container": "div", "title": ["a"], "exclude_links": ["("]}}}`, "invalid exclude_links pattern"},
+		{"redirect without param", `{"engines": {"x": {"container": "div", "title": ["a"], "redirects": [{"match": "/l/"}]}}}`, "has no param"},
+		{"relative url", `{"engines": {"x": {"url": "/search", "container": "div", "title": ["a"]}}}`, "expected an absolute URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseScraperFile([]byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
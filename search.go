@@ -0,0 +1,344 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rrfK dampens the weight of top ranks in reciprocal rank fusion; 60 is the
+// value from the original RRF paper and works well for short result lists
+const rrfK = 60
+
+// performWebSearch answers from the memory or disk cache when it can. Otherwise
+// concurrent identical searches share one upstream search, whose result is
+// cached unless it is degraded.
+func (s *WebSearchServer) performWebSearch(ctx context.Context, query string, maxResults int) (*SearchResponse, error) {
+	q := Query{Text: query, MaxResults: maxResults}
+
+	// Choose provider via env (default: auto -> enabled providers in registry order)
+	name := strings.ToLower(strings.TrimSpace(os.Getenv("SEARCH_PROVIDER")))
+
+	key := cacheKey(name, s.providers.Enabled(), q)
+	if res, ok := s.cache.get(key); ok {
+		s.stats.IncrementCacheHits()
+		return res, nil
+	}
+	if s.disk != nil {
+		if res, ok := s.disk.get(key); ok {
+			s.stats.IncrementDiskCacheHits()
+			s.cache.put(key, res)
+			return res, nil
+		}
+	}
+	s.stats.IncrementCacheMisses()
+
+	res, shared, err := s.flights.do(ctx, key, func(ctx context.Context) (*SearchResponse, error) {
+		res, err := s.search(ctx, name, q)
+		if err != nil {
+			return nil, err
+		}
+		if res.degraded {
+			// Cached, a fallback answer would outlive the outage behind it
+			return res, nil
+		}
+		s.cache.put(key, res)
+		if s.disk != nil {
+			// Callers fill in per-request fields, so the write gets its own copy
+			s.disk.putAsync(key, res.clone(), s.logger.Printf)
+		}
+		return res, nil
+	})
+	if shared {
+		s.stats.IncrementCoalescedSearches()
+	}
+	return res, err
+}
+
+// search runs q with the strategy named by SEARCH_PROVIDER
+func (s *WebSearchServer) search(ctx context.Context, name string, q Query) (*SearchResponse, error) {
+	if name != "" && name != "auto" && name != "merge" {
+		if p, ok := s.providers.Get(name); ok {
+			return s.searchProvider(ctx, p, q)
+		}
+		// Unknown provider -> auto fallback
+	}
+
+	providers := s.rotation()
+	var (
+		res *SearchResponse
+		err error
+	)
+	if name == "merge" {
+		timeout := envDuration("SEARCH_MERGE_TIMEOUT", 15*time.Second)
+		res, err = s.searchMerge(ctx, providers, q, timeout)
+	} else if delay := envDuration("SEARCH_HEDGE_DELAY", 0); delay > 0 {
+		res, err = s.searchHedged(ctx, providers, q, delay)
+	} else {
+		res, err = s.searchFallback(ctx, providers, q)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// A merge is missing every provider out of rotation; a fallback only
+	// those it would have asked before the one that answered
+	answered := ""
+	if name != "merge" && len(res.Providers) > 0 {
+		answered = res.Providers[0]
+	}
+	if s.skippedBefore(providers, answered) {
+		res.degraded = true
+	}
+	return res, nil
+}
+
+// skippedBefore reports whether an enabled provider that rotation left out
+// comes before the named one in registry order. With an empty name it reports
+// whether any was left out.
+func (s *WebSearchServer) skippedBefore(rotation []SearchProvider, name string) bool {
+	inRotation := make(map[string]bool, len(rotation))
+	for _, p := range rotation {
+		inRotation[p.Name()] = true
+	}
+	for _, p := range s.providers.Enabled() {
+		if p.Name() == name {
+			return false
+		}
+		if !inRotation[p.Name()] {
+			return true
+		}
+	}
+	return false
+}
+
+// rotation returns the enabled providers that are neither benched for
+// blocking us nor behind an open circuit breaker. When none is left, all are
+// returned and searchProvider fails the tripped ones fast.
+func (s *WebSearchServer) rotation() []SearchProvider {
+	enabled := s.providers.Enabled()
+	healthy := make([]SearchProvider, 0, len(enabled))
+	for _, p := range enabled {
+		if s.health.available(p.Name()) {
+			healthy = append(healthy, p)
+		}
+	}
+	if len(healthy) == 0 {
+		return enabled
+	}
+	return s.backoff.available(healthy)
+}
+
+// searchProvider runs a single provider, stamps its name on the results and
+// canonicalizes their URLs so every strategy sees deduplicated lists. Calls
+// go through the provider's circuit breaker.
+func (s *WebSearchServer) searchProvider(ctx context.Context, p SearchProvider, q Query) (*SearchResponse, error) {
+	if !s.health.acquire(p.Name()) {
+		s.stats.IncrementProviderError(ErrUnavailable)
+		return nil, newProviderError(p.Name(), ErrUnavailable, errCircuitOpen)
+	}
+	start := time.Now()
+	res, err := s.retry.do(ctx, func() (*SearchResponse, error) {
+		// Every attempt, retries included, counts against the rate limit
+		if bucket := s.limits[p.Name()]; bucket != nil {
+			if err := bucket.wait(ctx, s.maxQueueWait); err != nil {
+				if errors.Is(err, errThrottled) {
+					return nil, newProviderError(p.Name(), ErrThrottled, err)
+				}
+				return nil, err
+			}
+		}
+		res, err := p.Search(ctx, q)
+		if err != nil {
+			return nil, asProviderError(p.Name(), err)
+		}
+		return res, nil
+	})
+	if err != nil {
+		// Neither cancellation nor our own throttling says anything about
+		// the provider's health
+		if errors.Is(err, context.Canceled) {
+			s.health.release(p.Name())
+			return nil, err
+		}
+		if errors.Is(err, errThrottled) {
+			s.health.release(p.Name())
+			s.stats.IncrementProviderError(ErrThrottled)
+			return nil, err
+		}
+		s.health.record(p.Name(), false, time.Since(start))
+		for _, pe := range providerErrors(err) {
+			s.stats.IncrementProviderError(pe.Kind)
+			if pe.Kind == ErrBlocked {
+				pause := s.backoff.block(p.Name())
+				s.logger.Printf("Provider %s is blocking requests; out of rotation for %s", p.Name(), pause)
+			}
+		}
+		return nil, err
+	}
+	s.health.record(p.Name(), true, time.Since(start))
+	s.backoff.reset(p.Name())
+	for i := range res.Results {
+		if len(res.Results[i].Providers) == 0 {
+			res.Results[i].Providers = []string{p.Name()}
+		}
+	}
+	res.Results = canonicalizeResults(res.Results)
+	res.Count = len(res.Results)
+	res.Providers = []string{p.Name()}
+	return res, nil
+}
+
+// searchFallback tries providers in order and returns the first non-empty
+// result list. If none has results, the last provider's outcome is returned.
+func (s *WebSearchServer) searchFallback(ctx context.Context, providers []SearchProvider, q Query) (*SearchResponse, error) {
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("no search providers enabled")
+	}
+
+	var (
+		res      *SearchResponse
+		err      error
+		failures []error
+	)
+	for _, p := range providers {
+		res, err = s.searchProvider(ctx, p, q)
+		if err == nil && len(res.Results) > 0 {
+			res.degraded = len(failures) > 0
+			return res, nil
+		}
+		if err != nil {
+			s.logger.Printf("Provider %v", err)
+			failures = append(failures, err)
+		}
+	}
+	if err != nil {
+		// Report every provider that failed, not just the last one
+		return nil, errors.Join(failures...)
+	}
+	res.degraded = len(failures) > 0
+	return res, nil
+}
+
+// searchMerge queries all providers concurrently under a shared deadline and
+// fuses whatever lists came back before it. It only fails when no provider
+// produced a response at all.
+func (s *WebSearchServer) searchMerge(ctx context.Context, providers []SearchProvider, q Query, timeout time.Duration) (*SearchResponse, error) {
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("no search providers enabled")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type outcome struct {
+		res *SearchResponse
+		err error
+	}
+	outcomes := make([]outcome, len(providers))
+
+	var wg sync.WaitGroup
+	for i, p := range providers {
+		wg.Add(1)
+		go func(i int, p SearchProvider) {
+			defer wg.Done()
+			res, err := s.searchProvider(ctx, p, q)
+			outcomes[i] = outcome{res: res, err: err}
+		}(i, p)
+	}
+	wg.Wait()
+
+	var (
+		lists    []*SearchResponse
+		failures []error
+	)
+	for _, o := range outcomes {
+		if o.err != nil {
+			s.logger.Printf("Provider %v", o.err)
+			failures = append(failures, o.err)
+			continue
+		}
+		lists = append(lists, o.res)
+	}
+	if len(lists) == 0 {
+		return nil, errors.Join(failures...)
+	}
+
+	results := fuseResults(lists, q.MaxResults)
+	merged := &SearchResponse{Query: q.Text, Results: results, Count: len(results), degraded: len(failures) > 0}
+	for _, l := range lists {
+		merged.Providers = append(merged.Providers, l.Providers...)
+	}
+	return merged, nil
+}
+
+// fuseResults combines ranked lists with reciprocal rank fusion: every list a
+// URL appears in adds 1/(k+rank) to its score, so URLs that several
+// independent engines agree on rise to the top. Lists are matched on
+// CanonicalURL, so they must already have gone through canonicalizeResults.
+func fuseResults(lists []*SearchResponse, maxResults int) []SearchResult {
+	type fused struct {
+		result SearchResult
+		score  float64
+		order  int
+	}
+	byURL := make(map[string]*fused)
+	var entries []*fused
+
+	for _, list := range lists {
+		for i, r := range list.Results {
+			key := r.CanonicalURL
+			if key == "" {
+				key = r.URL
+			}
+			score := 1.0 / float64(rrfK+i+1)
+			if f, ok := byURL[key]; ok {
+				f.score += score
+				mergeResult(&f.result, r)
+				continue
+			}
+			f := &fused{result: r, score: score, order: len(entries)}
+			f.result.Providers = append([]string(nil), r.Providers...)
+			byURL[key] = f
+			entries = append(entries, f)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].score != entries[j].score {
+			return entries[i].score > entries[j].score
+		}
+		return entries[i].order < entries[j].order
+	})
+
+	if maxResults > 0 && len(entries) > maxResults {
+		entries = entries[:maxResults]
+	}
+	results := make([]SearchResult, len(entries))
+	for i, f := range entries {
+		results[i] = f.result
+		results[i].Rank = i + 1
+	}
+	return results
+}
+
+func appendUnique(list []string, values ...string) []string {
+	for _, v := range values {
+		found := false
+		for _, existing := range list {
+			if existing == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, v)
+		}
+	}
+	return list
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWebSearchServer_SearchFallback(t *testing.T) {
+	failing := &stubProvider{name: "failing", err: errors.New("boom")}
+	empty := &stubProvider{name: "empty"}
+	good := &stubProvider{name: "good", results: []SearchResult{{Title: "Hit", URL: "https://example.com", Rank: 1}}}
+	server := newTestServer(failing, empty, good)
+
+	res, err := server.searchFallback(context.Background(), server.providers.Enabled(), Query{Text: "q", MaxResults: 5})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if res.Count != 1 || res.Results[0].Title != "Hit" {
+		t.Errorf("Expected result from 'good' provider, got %+v", res)
+	}
+	if failing.calls != 1 || empty.calls != 1 {
+		t.Errorf("Expected earlier providers to be tried once, got %d and %d", failing.calls, empty.calls)
+	}
+}
+
+func TestWebSearchServer_SearchMerge(t *testing.T) {
+	a := &stubProvider{name: "a", results: []SearchResult{
+		{Title: "Only A", URL: "https://a.example", Rank: 1},
+		{Title: "Shared", URL: "https://shared.example", Rank: 2},
+	}}
+	b := &stubProvider{name: "b", results: []SearchResult{
+		{Title: "Shared", URL: "https://shared.example", Description: "from b", Rank: 1},
+		{Title: "Only B", URL: "https://b.example", Rank: 2},
+	}}
+	failing := &stubProvider{name: "failing", err: errors.New("boom")}
+	server := newTestServer(a, b, failing)
+
+	res, err := server.searchMerge(context.Background(), server.providers.Enabled(), Query{Text: "q", MaxResults: 10}, time.Second)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if res.Count != 3 {
+		t.Fatalf("Expected 3 fused results, got %d", res.Count)
+	}
+
+	top := res.Results[0]
+	if top.URL != "https://shared.example" {
+		t.Errorf("Expected URL found by both providers to rank first, got %s", top.URL)
+	}
+	if len(top.Providers) != 2 || top.Description != "from b" {
+		t.Errorf("Expected fused result to record both providers and keep a description, got %+v", top)
+	}
+	for i, r := range res.Results {
+		if r.Rank != i+1 {
+			t.Errorf("Expected rank %d, got %d", i+1, r.Rank)
+		}
+	}
+}
+
+// blockingProvider waits until its context is done
+type blockingProvider struct{ name string }
+
+func (p *blockingProvider) Name() string { return p.name }
+
+func (p *blockingProvider) Capabilities() ProviderCapabilities { return ProviderCapabilities{} }
+
+func (p *blockingProvider) Search(ctx context.Context, q Query) (*SearchResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestWebSearchServer_SearchCancelled(t *testing.T) {
+	server := newTestServer(&blockingProvider{name: "slow"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := server.performWebSearch(ctx, "q", 5)
+		done <- err
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Search did not return after cancellation")
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Session holds per-connection protocol state. stdio has exactly one session;
+// network transports create one per client.
+type Session struct {
+	ID string
+
+	mu          sync.RWMutex
+	initialized bool
+	// protocolVersion is the revision agreed on in initialize
+	protocolVersion string
+	lastSeen        time.Time
+
+	// inFlight is per session because request IDs are only unique within one
+	inFlight *inFlightRequests
+	// outbound carries server-initiated messages to whichever stream the
+	// transport keeps open for this session
+	outbound chan interface{}
+	closed   chan struct{}
+	once     sync.Once
+}
+
+func NewSession() *Session {
+	return &Session{
+		ID:       newSessionID(),
+		lastSeen: time.Now(),
+		inFlight: newInFlightRequests(),
+		outbound: make(chan interface{}, 16),
+		closed:   make(chan struct{}),
+	}
+}
+
+// newSessionID returns a random, unguessable session identifier
+func newSessionID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("crypto/rand failed: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+// markInitialized records a successful initialize handshake and the
+// protocol revision it negotiated
+func (sess *Session) markInitialized(version string) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	sess.initialized = true
+	sess.protocolVersion = version
+}
+
+// Initialized reports whether initialize has been answered for this session
+func (sess *Session) Initialized() bool {
+	sess.mu.RLock()
+	defer sess.mu.RUnlock()
+	return sess.initialized
+}
+
+// ProtocolVersion returns the negotiated protocol revision, or "" before
+// initialize
+func (sess *Session) ProtocolVersion() string {
+	sess.mu.RLock()
+	defer sess.mu.RUnlock()
+	return sess.protocolVersion
+}
+
+func (sess *Session) touch() {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	sess.lastSeen = time.Now()
+}
+
+func (sess *Session) idleSince() time.Time {
+	sess.mu.RLock()
+	defer sess.mu.RUnlock()
+	return sess.lastSeen
+}
+
+// close ends the session, which stops its streams
+func (sess *Session) close() {
+	sess.once.Do(func() { close(sess.closed) })
+}
+
+// sessionStore tracks the sessions of network transports by ID. It holds at
+// most max sessions; adding one more closes the one idle the longest.
+type sessionStore struct {
+	max int
+
+	mu       sync.RWMutex
+	sessions map[string]*Session
+}
+
+func newSessionStore(max int) *sessionStore {
+	if max <= 0 {
+		max = 1
+	}
+	return &sessionStore{max: max, sessions: make(map[string]*Session)}
+}
+
+// add stores a session whose initialize succeeded
+func (st *sessionStore) add(sess *Session) {
+	var evicted *Session
+	st.mu.Lock()
+	if len(st.sessions) >= st.max {
+		for _, other := range st.sessions {
+			if evicted == nil || other.idleSince().Before(evicted.idleSince()) {
+				evicted = other
+			}
+		}
+		delete(st.sessions, evicted.ID)
+	}
+	st.sessions[sess.ID] = sess
+	st.mu.Unlock()
+	if evicted != nil {
+		evicted.close()
+	}
+}
+
+func (st *sessionStore) len() int {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return len(st.sessions)
+}
+
+func (st *sessionStore) get(id string) (*Session, bool) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	sess, ok := st.sessions[id]
+	return sess, ok
+}
+
+func (st *sessionStore) remove(id string) {
+	st.mu.Lock()
+	sess, ok := st.sessions[id]
+	delete(st.sessions, id)
+	st.mu.Unlock()
+	if ok {
+		sess.close()
+	}
+}
+
+// expire closes sessions idle for longer than ttl and returns how many
+func (st *sessionStore) expire(ttl time.Duration) int {
+	cutoff := time.Now().Add(-ttl)
+	var stale []string
+	st.mu.RLock()
+	for id, sess := range st.sessions {
+		if sess.idleSince().Before(cutoff) {
+			stale = append(stale, id)
+		}
+	}
+	st.mu.RUnlock()
+
+	for _, id := range stale {
+		st.remove(id)
+	}
+	return len(stale)
+}
+
+type sessionContextKey struct{}
+
+func withSession(ctx context.Context, sess *Session) context.Context {
+	return context.WithValue(ctx, sessionContextKey{}, sess)
+}
+
+// sessionFromContext returns the session a message arrived on, or nil when
+// the message is handled outside a transport (e.g. directly in tests)
+func sessionFromContext(ctx context.Context) *Session {
+	sess, _ := ctx.Value(sessionContextKey{}).(*Session)
+	return sess
+}
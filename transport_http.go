@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// sessionHeader carries the session ID on Streamable HTTP requests
+	sessionHeader = "Mcp-Session-Id"
+
+	maxRequestBody = 4 << 20
+	sseKeepAlive   = 25 * time.Second
+)
+
+// handleStreamableHTTP implements the MCP Streamable HTTP transport on a
+// single endpoint: POST carries client messages, GET opens a stream for
+// server-initiated messages and DELETE ends the session.
+func (s *WebSearchServer) handleStreamableHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		s.handleMCPPost(w, r)
+	case http.MethodGet:
+		s.handleMCPStream(w, r)
+	case http.MethodDelete:
+		s.handleMCPDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		writeHTTPError(w, http.StatusMethodNotAllowed, -32600, "Method not allowed")
+	}
+}
+
+func (s *WebSearchServer) handleMCPPost(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeHTTPError(w, http.StatusRequestEntityTooLarge, -32600, "Request body too large")
+			return
+		}
+		writeHTTPError(w, http.StatusBadRequest, -32700, "Parse error")
+		return
+	}
+
+	var sess *Session
+	if id := r.Header.Get(sessionHeader); id != "" {
+		var ok bool
+		if sess, ok = s.sessions.get(id); !ok {
+			writeHTTPError(w, http.StatusNotFound, -32600, "Session not found")
+			return
+		}
+		if problem, ok := checkProtocolVersionHeader(r, sess); !ok {
+			writeHTTPError(w, http.StatusBadRequest, -32600, problem)
+			return
+		}
+	} else if containsInitialize(body) {
+		// The session is only stored, and its ID handed out, once
+		// initialize has succeeded
+		sess = NewSession()
+	} else {
+		writeHTTPError(w, http.StatusBadRequest, -32600, "Missing "+sessionHeader+" header")
+		return
+	}
+	sess.touch()
+
+	// Searches can outlive the server-wide write timeout
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	ctx := withSession(r.Context(), sess)
+	var reply interface{}
+	if isBatch(body) {
+		reply = s.handleBatch(ctx, body, make(chan struct{}, s.maxConcurrency))
+	} else {
+		msg, errResponse := decodeMessage(body)
+		if errResponse != nil {
+			s.stats.IncrementErrors()
+			writeJSON(w, http.StatusBadRequest, errResponse)
+			return
+		}
+		s.stats.IncrementRequests()
+		if response := s.handleMessage(ctx, msg); response != nil {
+			reply = response
+		}
+	}
+	if r.Header.Get(sessionHeader) == "" {
+		if !sess.Initialized() {
+			sess.close()
+		} else {
+			s.sessions.add(sess)
+			w.Header().Set(sessionHeader, sess.ID)
+		}
+	}
+
+	if reply == nil {
+		// Only notifications or responses: nothing to send back
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if prefersEventStream(r) {
+		setSSEHeaders(w)
+		w.WriteHeader(http.StatusOK)
+		if err := writeSSEEvent(w, "message", reply); err != nil {
+			s.logger.Printf("Error writing SSE response: %v", err)
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, reply)
+}
+
+// handleMCPStream keeps an SSE stream open for server-initiated messages
+func (s *WebSearchServer) handleMCPStream(w http.ResponseWriter, r *http.Request) {
+	if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		writeHTTPError(w, http.StatusNotAcceptable, -32600, "Accept must include text/event-stream")
+		return
+	}
+	sess, ok := s.sessionFromHeader(w, r)
+	if !ok {
+		return
+	}
+
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	setSSEHeaders(w)
+	w.WriteHeader(http.StatusOK)
+	flush(w)
+
+	s.streamSession(r.Context(), w, sess, func(msg interface{}) error {
+		return writeSSEEvent(w, "message", msg)
+	})
+}
+
+// streamSession forwards the session's outbound messages through send and
+// writes SSE keep-alive comments until the client disconnects or the session
+// ends
+func (s *WebSearchServer) streamSession(ctx context.Context, w http.ResponseWriter, sess *Session, send func(interface{}) error) {
+	ticker := time.NewTicker(sseKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sess.closed:
+			return
+		case msg := <-sess.outbound:
+			if err := send(msg); err != nil {
+				s.logger.Printf("Error writing to session %s stream: %v", sess.ID, err)
+				return
+			}
+		case <-ticker.C:
+			sess.touch()
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flush(w)
+		}
+	}
+}
+
+func (s *WebSearchServer) handleMCPDelete(w http.ResponseWriter, r *http.Request) {
+	sess, ok := s.sessionFromHeader(w, r)
+	if !ok {
+		return
+	}
+	s.sessions.remove(sess.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sessionFromHeader looks up the session named by the Mcp-Session-Id header,
+// writing the error response itself when there is none
+func (s *WebSearchServer) sessionFromHeader(w http.ResponseWriter, r *http.Request) (*Session, bool) {
+	id := r.Header.Get(sessionHeader)
+	if id == "" {
+		writeHTTPError(w, http.StatusBadRequest, -32600, "Missing "+sessionHeader+" header")
+		return nil, false
+	}
+	sess, ok := s.sessions.get(id)
+	if !ok {
+		writeHTTPError(w, http.StatusNotFound, -32600, "Session not found")
+		return nil, false
+	}
+	if problem, ok := checkProtocolVersionHeader(r, sess); !ok {
+		writeHTTPError(w, http.StatusBadRequest, -32600, problem)
+		return nil, false
+	}
+	sess.touch()
+	return sess, true
+}
+
+// expireSessions periodically drops sessions that have been idle for ttl
+func (s *WebSearchServer) expireSessions(ctx context.Context, ttl time.Duration) {
+	interval := ttl / 2
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n := s.sessions.expire(ttl); n > 0 {
+				s.logger.Printf("Expired %d idle session(s)", n)
+			}
+		}
+	}
+}
+
+// containsInitialize reports whether a POST body holds an initialize request,
+// the only message allowed without a session
+func containsInitialize(body []byte) bool {
+	var msgs []struct {
+		Method string `json:"method"`
+	}
+	if isBatch(body) {
+		if err := json.Unmarshal(body, &msgs); err != nil {
+			return false
+		}
+	} else {
+		msgs = append(msgs, struct {
+			Method string `json:"method"`
+		}{})
+		if err := json.Unmarshal(body, &msgs[0]); err != nil {
+			return false
+		}
+	}
+	for _, m := range msgs {
+		if m.Method == "initialize" {
+			return true
+		}
+	}
+	return false
+}
+
+// prefersEventStream reports whether the client asked for SSE only
+func prefersEventStream(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "text/event-stream") && !strings.Contains(accept, "application/json")
+}
+
+func setSSEHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+}
+
+func writeSSEEvent(w http.ResponseWriter, event string, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b); err != nil {
+		return err
+	}
+	flush(w)
+	return nil
+}
+
+func flush(w http.ResponseWriter) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeHTTPError answers a transport-level failure with a JSON-RPC error body
+func writeHTTPError(w http.ResponseWriter, status int, code int, message string) {
+	writeJSON(w, status, &MCPMessage{
+		JSONRPC: "2.0",
+		Error: &MCPError{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
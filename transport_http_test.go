@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func postMCP(t *testing.T, url, sessionID, accept, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", accept)
+	if sessionID != "" {
+		req.Header.Set(sessionHeader, sessionID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestStreamableHTTP_SessionLifecycle(t *testing.T) {
+	server := NewWebSearchServer()
+	ts := httptest.NewServer(server.httpHandler())
+	defer ts.Close()
+	endpoint := ts.URL + "/mcp"
+	accept := "application/json, text/event-stream"
+
+	resp := postMCP(t, endpoint, "", accept, `{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a session, got %d", resp.StatusCode)
+	}
+
+	resp = postMCP(t, endpoint, "", accept, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`)
+	sessionID := resp.Header.Get(sessionHeader)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || sessionID == "" {
+		t.Fatalf("Expected 200 with a session ID, got %d %q", resp.StatusCode, sessionID)
+	}
+
+	resp = postMCP(t, endpoint, sessionID, accept, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("Expected 202 for a notification, got %d", resp.StatusCode)
+	}
+
+	resp = postMCP(t, endpoint, sessionID, accept, `[{"jsonrpc":"2.0","id":2,"method":"ping"},{"jsonrpc":"2.0","id":3,"method":"tools/list"}]`)
+	var batch []map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+		t.Fatalf("Failed to decode batch reply: %v", err)
+	}
+	resp.Body.Close()
+	if len(batch) != 2 || batch[0]["result"] != "pong" {
+		t.Errorf("Expected batch reply with pong first, got %v", batch)
+	}
+
+	resp = postMCP(t, endpoint, sessionID, "text/event-stream", `{"jsonrpc":"2.0","id":4,"method":"ping"}`)
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected an SSE response, got %q", ct)
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	var data string
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "data: ") {
+			data = strings.TrimPrefix(scanner.Text(), "data: ")
+			break
+		}
+	}
+	resp.Body.Close()
+	if !strings.Contains(data, `"pong"`) {
+		t.Errorf("Expected pong in SSE data, got %q", data)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, endpoint, nil)
+	req.Header.Set(sessionHeader, sessionID)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected 204 on DELETE, got %d", resp.StatusCode)
+	}
+
+	resp = postMCP(t, endpoint, sessionID, accept, `{"jsonrpc":"2.0","id":5,"method":"ping"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for a terminated session, got %d", resp.StatusCode)
+	}
+}
+
+func TestStreamableHTTP_FailedInitializeCreatesNoSession(t *testing.T) {
+	server := NewWebSearchServer()
+	ts := httptest.NewServer(server.httpHandler())
+	defer ts.Close()
+
+	resp := postMCP(t, ts.URL+"/mcp", "", "application/json", `{"jsonrpc":"1.0","id":1,"method":"initialize"}`)
+	resp.Body.Close()
+	if id := resp.Header.Get(sessionHeader); id != "" {
+		t.Errorf("Expected no session ID for a failed initialize, got %q", id)
+	}
+	if n := server.sessions.len(); n != 0 {
+		t.Errorf("Expected no stored sessions, got %d", n)
+	}
+}
+
+func TestStreamableHTTP_SessionLimit(t *testing.T) {
+	server := NewWebSearchServer()
+	server.sessions = newSessionStore(2)
+	ts := httptest.NewServer(server.httpHandler())
+	defer ts.Close()
+	endpoint := ts.URL + "/mcp"
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		resp := postMCP(t, endpoint, "", "application/json", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`)
+		resp.Body.Close()
+		ids = append(ids, resp.Header.Get(sessionHeader))
+		// Give every session a distinct last-seen time
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := server.sessions.len(); n != 2 {
+		t.Fatalf("Expected the store to hold 2 sessions, got %d", n)
+	}
+
+	resp := postMCP(t, endpoint, ids[0], "application/json", `{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected the longest idle session to be closed, got status %d", resp.StatusCode)
+	}
+	resp = postMCP(t, endpoint, ids[2], "application/json", `{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected the newest session to be kept, got status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// sseConnection is one client of the legacy HTTP+SSE transport (protocol
+// revision 2024-11-05): responses to messages POSTed for the session are
+// written to its event stream
+type sseConnection struct {
+	sess *Session
+	ctx  context.Context
+	d    *dispatcher
+}
+
+type sseConnections struct {
+	mu    sync.RWMutex
+	conns map[string]*sseConnection
+}
+
+func newSSEConnections() *sseConnections {
+	return &sseConnections{conns: make(map[string]*sseConnection)}
+}
+
+func (c *sseConnections) add(conn *sseConnection) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conns[conn.sess.ID] = conn
+}
+
+func (c *sseConnections) get(id string) (*sseConnection, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	conn, ok := c.conns[id]
+	return conn, ok
+}
+
+func (c *sseConnections) remove(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.conns, id)
+}
+
+// handleLegacySSE opens the event stream. The first event tells the client
+// where to POST its messages; every response follows as a "message" event.
+func (s *WebSearchServer) handleLegacySSE(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeHTTPError(w, http.StatusMethodNotAllowed, -32600, "Method not allowed")
+		return
+	}
+
+	sess := NewSession()
+	ctx, cancel := context.WithCancel(withSession(r.Context(), sess))
+	defer cancel()
+
+	d := s.newDispatcher(s.maxConcurrency, func(msg interface{}) error {
+		select {
+		case sess.outbound <- msg:
+			return nil
+		case <-ctx.Done():
+			return errors.New("event stream closed")
+		}
+	})
+	s.legacySSE.add(&sseConnection{sess: sess, ctx: ctx, d: d})
+
+	s.stats.IncrementConnections()
+	defer s.stats.DecrementActiveConnections()
+	s.logger.Printf("SSE client connected from %s (session %s)", r.RemoteAddr, sess.ID)
+
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	setSSEHeaders(w)
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.WriteString(w, "event: endpoint\ndata: /messages?sessionId="+sess.ID+"\n\n"); err == nil {
+		flush(w)
+		s.streamSession(ctx, w, sess, func(msg interface{}) error {
+			return writeSSEEvent(w, "message", msg)
+		})
+	}
+
+	// Stop routing POSTs here, then abort and drain in-flight requests
+	s.legacySSE.remove(sess.ID)
+	sess.close()
+	cancel()
+	d.wait()
+	s.logger.Printf("SSE client %s disconnected", r.RemoteAddr)
+}
+
+// handleLegacyMessage accepts a client message for an open event stream.
+// The response goes out on the stream, so the POST itself only gets 202.
+func (s *WebSearchServer) handleLegacyMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeHTTPError(w, http.StatusMethodNotAllowed, -32600, "Method not allowed")
+		return
+	}
+
+	conn, ok := s.legacySSE.get(r.URL.Query().Get("sessionId"))
+	if !ok {
+		writeHTTPError(w, http.StatusNotFound, -32600, "Session not found")
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	if err != nil {
+		writeHTTPError(w, http.StatusBadRequest, -32700, "Parse error")
+		return
+	}
+
+	conn.d.dispatchPayload(conn.ctx, body)
+	w.WriteHeader(http.StatusAccepted)
+}
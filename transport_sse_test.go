@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// readSSEEvent returns the next event name and data from an SSE stream,
+// skipping keep-alive comments
+func readSSEEvent(t *testing.T, r *bufio.Reader) (string, string) {
+	t.Helper()
+	var event, data string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Stream ended early: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "" && data != "":
+			return event, data
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestLegacySSE_RoundTrip(t *testing.T) {
+	server := NewWebSearchServer()
+	ts := httptest.NewServer(server.httpHandler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/sse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	stream := bufio.NewReader(resp.Body)
+
+	event, endpoint := readSSEEvent(t, stream)
+	if event != "endpoint" || !strings.HasPrefix(endpoint, "/messages?sessionId=") {
+		t.Fatalf("Expected endpoint event, got %q %q", event, endpoint)
+	}
+
+	post, err := http.Post(ts.URL+endpoint, "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	post.Body.Close()
+	if post.StatusCode != http.StatusAccepted {
+		t.Errorf("Expected 202 for posted message, got %d", post.StatusCode)
+	}
+
+	event, data := readSSEEvent(t, stream)
+	if event != "message" || !strings.Contains(data, `"pong"`) {
+		t.Errorf("Expected pong message event, got %q %q", event, data)
+	}
+
+	post, err = http.Post(ts.URL+"/messages?sessionId=unknown", "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":2,"method":"ping"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	post.Body.Close()
+	if post.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown session, got %d", post.StatusCode)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const wsWriteTimeout = 10 * time.Second
+
+// handleWebSocket runs the JSON-RPC loop over a WebSocket connection. Each
+// text message holds one JSON-RPC message or batch; requests are handled
+// concurrently like in stdio mode and each connection is its own session.
+func (s *WebSearchServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !websocket.IsWebSocketUpgrade(r) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		writeHTTPError(w, http.StatusUpgradeRequired, -32600, "WebSocket upgrade required")
+		return
+	}
+
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  4096,
+		WriteBufferSize: 4096,
+		// The route already applies the origin policy; checking again here
+		// keeps the upgrader from falling back to its same-host rule
+		CheckOrigin: s.origins.allowed,
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written an HTTP error
+		s.logger.Printf("WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(maxRequestBody)
+
+	s.stats.IncrementConnections()
+	defer s.stats.DecrementActiveConnections()
+	s.logger.Printf("WebSocket client connected from %s", r.RemoteAddr)
+
+	ctx, cancel := context.WithCancel(withSession(r.Context(), NewSession()))
+	defer cancel()
+
+	d := s.newDispatcher(s.maxConcurrency, func(msg interface{}) error {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		return conn.WriteJSON(msg)
+	})
+
+	// Hijacked connections are not closed by http.Server.Shutdown, so close
+	// it ourselves when the server context ends
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-r.Context().Done():
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+				time.Now().Add(time.Second))
+			conn.Close()
+		case <-finished:
+		}
+	}()
+
+	for {
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				s.logger.Printf("WebSocket read error: %v", err)
+			}
+			break
+		}
+		if msgType != websocket.TextMessage {
+			continue
+		}
+
+		select {
+		case err := <-d.errs():
+			s.logger.Printf("WebSocket write error: %v", err)
+			cancel()
+			d.wait()
+			return
+		default:
+		}
+
+		d.dispatchPayload(ctx, data)
+	}
+
+	// The client is gone: abort its in-flight searches before waiting on them
+	cancel()
+	d.wait()
+	s.logger.Printf("WebSocket client %s disconnected", r.RemoteAddr)
+}
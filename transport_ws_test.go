@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWebSocket_RoundTrip(t *testing.T) {
+	server := NewWebSearchServer()
+	ts := httptest.NewServer(server.httpHandler())
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/", nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}`)); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var response MCPMessage
+	if err := conn.ReadJSON(&response); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if response.Result != "pong" {
+		t.Errorf("Expected pong, got %+v", response)
+	}
+
+	stats := server.stats.GetStats()
+	if stats["connection_count"] != int64(1) || stats["active_connections"] != int64(1) {
+		t.Errorf("Expected one active connection, got %v/%v", stats["connection_count"], stats["active_connections"])
+	}
+
+	conn.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for server.stats.GetStats()["active_connections"] != int64(0) {
+		if time.Now().After(deadline) {
+			t.Fatal("Active connections were not decremented after disconnect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWebSocket_RejectsForeignOrigin(t *testing.T) {
+	server := NewWebSearchServer()
+	ts := httptest.NewServer(server.httpHandler())
+	defer ts.Close()
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/"
+
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Origin": {"https://evil.example"}})
+	if err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Expected 403 for a foreign origin, got %v", err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Origin": {"http://localhost:3000"}})
+	if err != nil {
+		t.Fatalf("Expected a local origin to connect, got %v", err)
+	}
+	conn.Close()
+
+	t.Setenv("MCP_ALLOWED_ORIGINS", "https://app.example.com")
+	server = NewWebSearchServer()
+	allowed := httptest.NewServer(server.httpHandler())
+	defer allowed.Close()
+	conn, _, err = websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(allowed.URL, "http")+"/", http.Header{"Origin": {"https://app.example.com"}})
+	if err != nil {
+		t.Fatalf("Expected an opted-in origin to connect, got %v", err)
+	}
+	conn.Close()
+}